@@ -0,0 +1,229 @@
+package apperror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// Stable, machine-readable error codes. Clients match on these, so existing
+// values must never change meaning.
+const (
+	CodeInvalidJSON        = "INVALID_JSON"
+	CodeValidationFailed   = "VALIDATION_FAILED"
+	CodeInvalidParameter   = "INVALID_PARAMETER"
+	CodeUnsupportedMedia   = "UNSUPPORTED_MEDIA_TYPE"
+	CodeUnauthorized       = "UNAUTHORIZED"
+	CodeInvalidToken       = "INVALID_TOKEN"
+	CodeInvalidCredentials = "INVALID_CREDENTIALS"
+	CodeForbidden          = "FORBIDDEN"
+	CodeNotFound           = "NOT_FOUND"
+	CodeUserNotFound       = "USER_NOT_FOUND"
+	CodeCategoryNotFound   = "CATEGORY_NOT_FOUND"
+	CodeHabitNotFound      = "HABIT_NOT_FOUND"
+	CodeHabitLogNotFound   = "HABIT_LOG_NOT_FOUND"
+	CodeTagNotFound        = "TAG_NOT_FOUND"
+	CodeGoalNotFound       = "GOAL_NOT_FOUND"
+	CodeEmailTaken         = "EMAIL_TAKEN"
+	CodeDuplicateEntry     = "DUPLICATE_ENTRY"
+	CodeInvalidReference   = "INVALID_REFERENCE"
+	CodeResourceInUse      = "RESOURCE_IN_USE"
+	CodePreconditionFailed = "PRECONDITION_FAILED"
+	CodeConcurrentUpdate   = "CONCURRENT_UPDATE"
+	CodeBackfillWindow     = "OUTSIDE_BACKFILL_WINDOW"
+	CodeFileTooLarge       = "FILE_TOO_LARGE"
+	CodeAttachmentLimit    = "ATTACHMENT_LIMIT_REACHED"
+	CodeVacationOverlap    = "VACATION_OVERLAP"
+	CodeFreezeLimit        = "FREEZE_LIMIT_REACHED"
+	CodeAlreadyCompleted   = "ALREADY_COMPLETED"
+	CodeHabitPaused        = "HABIT_PAUSED"
+	CodeHabitNotPaused     = "HABIT_NOT_PAUSED"
+	CodeHabitArchived      = "HABIT_ARCHIVED"
+	CodeHabitNotArchived   = "HABIT_NOT_ARCHIVED"
+	CodeHabitNotTimed      = "HABIT_NOT_TIMED"
+	CodeTimerRunning       = "TIMER_RUNNING"
+	CodeTimerNotRunning    = "TIMER_NOT_RUNNING"
+	CodeInternal           = "INTERNAL_ERROR"
+)
+
+// MySQL server error numbers mapped by FromDB
+const (
+	mysqlDuplicateEntry  = 1062
+	mysqlRowIsReferenced = 1451
+	mysqlNoReferencedRow = 1452
+	mysqlDataTooLong     = 1406
+	mysqlLockDeadlock    = 1213
+)
+
+// ErrLogDayTaken is returned when a habit already has a log on the day a
+// log is written for, whether a handler or the unique index notices it
+var ErrLogDayTaken = Conflict(CodeDuplicateEntry, "Habit already has a log for that day").WithField("date", "already has a log")
+
+// duplicateKeyErrors are returned by FromDB instead of the generic
+// duplicate entry error, by the name of the unique index
+var duplicateKeyErrors = map[string]*Error{
+	"idx_habit_logs_habit_day": ErrLogDayTaken,
+}
+
+// Error is rendered to clients by middleware.ErrorHandler. Err holds the
+// underlying cause for logging and is never sent in the response.
+type Error struct {
+	Status  int               `json:"-"`
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+	Err     error             `json:"-"`
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithField returns a copy of e with an additional per-field message
+func (e *Error) WithField(field, message string) *Error {
+	clone := *e
+	clone.Fields = make(map[string]string, len(e.Fields)+1)
+	for k, v := range e.Fields {
+		clone.Fields[k] = v
+	}
+	clone.Fields[field] = message
+	return &clone
+}
+
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func BadRequest(code, message string) *Error {
+	return New(http.StatusBadRequest, code, message)
+}
+
+func Unauthorized(code, message string) *Error {
+	return New(http.StatusUnauthorized, code, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, message)
+}
+
+func NotFound(code, message string) *Error {
+	return New(http.StatusNotFound, code, message)
+}
+
+func Conflict(code, message string) *Error {
+	return New(http.StatusConflict, code, message)
+}
+
+func Internal(message string, err error) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Message: message, Err: err}
+}
+
+// Abort attaches err for middleware.ErrorHandler to render and stops the chain
+func Abort(c *gin.Context, err error) {
+	c.Error(err)
+	c.Abort()
+}
+
+// InvalidParam reports a malformed path or query parameter
+func InvalidParam(param, message string) *Error {
+	return BadRequest(CodeInvalidParameter, message).WithField(param, message)
+}
+
+// Validation converts a request binding error into a 400 with per-field details
+func Validation(err error) *Error {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		appErr := BadRequest(CodeValidationFailed, "Request validation failed")
+		appErr.Fields = make(map[string]string, len(validationErrs))
+		for _, fieldErr := range validationErrs {
+			appErr.Fields[fieldErr.Field()] = describeValidation(fieldErr)
+		}
+		appErr.Err = err
+		return appErr
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		appErr := BadRequest(CodeValidationFailed, "Request validation failed")
+		appErr.Fields = map[string]string{typeErr.Field: "must be of type " + typeErr.Type.String()}
+		appErr.Err = err
+		return appErr
+	}
+
+	appErr := BadRequest(CodeInvalidJSON, "Invalid request data")
+	appErr.Err = err
+	return appErr
+}
+
+// FromDB maps a GORM or MySQL error to an API error. notFound is returned for
+// gorm.ErrRecordNotFound; any unrecognised error becomes a 500 with message.
+func FromDB(err error, notFound *Error, message string) *Error {
+	if errors.Is(err, gorm.ErrRecordNotFound) && notFound != nil {
+		return notFound
+	}
+
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case mysqlDuplicateEntry:
+			for key, keyErr := range duplicateKeyErrors {
+				// MySQL ends the message with the index name, quoted and
+				// prefixed with the table on 8.0
+				if strings.HasSuffix(mysqlErr.Message, key+"'") {
+					clone := *keyErr
+					clone.Err = err
+					return &clone
+				}
+			}
+			return &Error{Status: http.StatusConflict, Code: CodeDuplicateEntry, Message: "A record with the same value already exists", Err: err}
+		case mysqlRowIsReferenced:
+			return &Error{Status: http.StatusConflict, Code: CodeResourceInUse, Message: "Record is still referenced by other records", Err: err}
+		case mysqlNoReferencedRow:
+			return &Error{Status: http.StatusUnprocessableEntity, Code: CodeInvalidReference, Message: "Referenced record does not exist", Err: err}
+		case mysqlDataTooLong:
+			return &Error{Status: http.StatusBadRequest, Code: CodeValidationFailed, Message: "A value is too long", Err: err}
+		case mysqlLockDeadlock:
+			return &Error{Status: http.StatusConflict, Code: CodeConcurrentUpdate, Message: "Request conflicted with a concurrent change; retry it", Err: err}
+		}
+	}
+
+	return Internal(message, err)
+}
+
+func describeValidation(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min":
+		return "must be at least " + fieldErr.Param()
+	case "max":
+		return "must be at most " + fieldErr.Param()
+	case "oneof":
+		return "must be one of: " + fieldErr.Param()
+	case "datetime":
+		return "must be in the format " + fieldErr.Param()
+	default:
+		return "failed the " + fieldErr.Tag() + " rule"
+	}
+}
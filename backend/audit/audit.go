@@ -0,0 +1,187 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"habit-tracker/models"
+)
+
+const (
+	ActionCreate         = "create"
+	ActionUpdate         = "update"
+	ActionDelete         = "delete"
+	ActionLogin          = "login"
+	ActionLoginFailed    = "login_failed"
+	ActionPasswordChange = "password_change"
+)
+
+const (
+	EntityUser         = "user"
+	EntityCategory     = "category"
+	EntityHabit        = "habit"
+	EntityHabitLog     = "habit_log"
+	EntityHabitItem    = "habit_item"
+	EntityAttachment   = "attachment"
+	EntityVacation     = "vacation"
+	EntityStreakFreeze = "streak_freeze"
+	EntityTimerSession = "timer_session"
+	EntityTemplate     = "habit_template"
+	EntityTag          = "tag"
+	EntityHabitTag     = "habit_tag"
+	EntityGoal         = "goal"
+	EntityAchievement  = "achievement"
+)
+
+// Entry describes a single change to be recorded
+type Entry struct {
+	ActorID    uint
+	Action     string
+	EntityType string
+	EntityID   uint
+	Before     interface{}
+	After      interface{}
+}
+
+// Change is the before/after value of a single field
+type Change struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// Fields that are relationships or bookkeeping and never part of a diff
+var ignoredFields = map[string]bool{
+	"updated_at":  true,
+	"user":        true,
+	"category":    true,
+	"habits":      true,
+	"habit":       true,
+	"habit_logs":  true,
+	"attachments": true,
+}
+
+// Record writes an audit entry using tx, so it commits or rolls back together
+// with the change it describes. Actor, IP and request ID are taken from c.
+func Record(tx *gorm.DB, c *gin.Context, entry Entry) error {
+	log := models.AuditLog{
+		Action:     entry.Action,
+		EntityType: entry.EntityType,
+		IP:         c.ClientIP(),
+		UserAgent:  truncate(c.Request.UserAgent(), 255),
+		RequestID:  c.GetString("requestID"),
+	}
+
+	if entry.ActorID != 0 {
+		id := entry.ActorID
+		log.ActorID = &id
+	} else if userID, exists := c.Get("userID"); exists {
+		if id, ok := userID.(uint); ok {
+			log.ActorID = &id
+		}
+	}
+	return write(tx, log, entry)
+}
+
+// RecordSystem writes an audit entry for a change made outside a request,
+// such as by a background job. The job's name is stored as the user agent and
+// entry.ActorID names the user on whose behalf it acted.
+func RecordSystem(tx *gorm.DB, job string, entry Entry) error {
+	log := models.AuditLog{
+		Action:     entry.Action,
+		EntityType: entry.EntityType,
+		UserAgent:  truncate(job, 255),
+	}
+	if entry.ActorID != 0 {
+		id := entry.ActorID
+		log.ActorID = &id
+	}
+	return write(tx, log, entry)
+}
+
+// write completes log with the entity and its snapshots and stores it
+func write(tx *gorm.DB, log models.AuditLog, entry Entry) error {
+	if entry.EntityID != 0 {
+		id := entry.EntityID
+		log.EntityID = &id
+	}
+
+	before, err := snapshot(entry.Before)
+	if err != nil {
+		return err
+	}
+	after, err := snapshot(entry.After)
+	if err != nil {
+		return err
+	}
+
+	if before != nil {
+		if log.Before, err = json.Marshal(before); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		if log.After, err = json.Marshal(after); err != nil {
+			return err
+		}
+	}
+	if before != nil && after != nil {
+		if log.Changes, err = json.Marshal(Diff(before, after)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Create(&log).Error
+}
+
+// Diff returns the fields whose values differ between before and after
+func Diff(before, after map[string]interface{}) map[string]Change {
+	changes := make(map[string]Change)
+	for key, from := range before {
+		to, exists := after[key]
+		if !exists || !reflect.DeepEqual(from, to) {
+			changes[key] = Change{From: from, To: to}
+		}
+	}
+	for key, to := range after {
+		if _, exists := before[key]; !exists {
+			changes[key] = Change{From: nil, To: to}
+		}
+	}
+	return changes
+}
+
+// snapshot converts a model into its JSON field map, dropping relationships
+func snapshot(v interface{}) (map[string]interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	for key := range ignoredFields {
+		delete(fields, key)
+	}
+	return fields, nil
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence,
+// which MySQL would reject
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,35 @@
+// Command recompute-xp rebuilds users' XP from their full log history. It
+// adds correction events for logs whose XP drifted, for instance after a
+// change to how XP is awarded, and resets the stored totals.
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+
+	"github.com/joho/godotenv"
+	"habit-tracker/database"
+	"habit-tracker/handlers"
+)
+
+func main() {
+	userID := flag.Uint("user", 0, "only recompute this user (default: all users)")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found")
+	}
+
+	database.InitDB()
+
+	var only *uint
+	if *userID != 0 {
+		id := *userID
+		only = &id
+	}
+	if err := handlers.RecomputeXP(only, time.Now()); err != nil {
+		log.Fatalf("Failed to recompute XP: %v", err)
+	}
+	log.Println("XP recomputed")
+}
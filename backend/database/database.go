@@ -7,6 +7,7 @@ import (
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"habit-tracker/models"
 )
 
@@ -29,19 +30,68 @@ func InitDB() {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
+	// Logs created before the status column existed only have completed
+	backfillLogStatus := !DB.Migrator().HasColumn(&models.HabitLog{}, "Status")
+	// Existing habits and categories keep their order when positions arrive
+	backfillPositions := !DB.Migrator().HasColumn(&models.Habit{}, "Position")
+
+	// The one-log-per-day index cannot be built over days that already have
+	// several logs, so stop with a clear message until they are merged
+	if DB.Migrator().HasTable(&models.HabitLog{}) && !DB.Migrator().HasIndex(&models.HabitLog{}, "idx_habit_logs_habit_day") {
+		var duplicateDays int64
+		DB.Raw("SELECT COUNT(*) FROM (SELECT habit_id FROM habit_logs GROUP BY habit_id, DATE(date) HAVING COUNT(*) > 1) AS days").
+			Scan(&duplicateDays)
+		if duplicateDays > 0 {
+			log.Fatalf("Failed to migrate database: %d habit days have more than one log; keep one log per habit and day first", duplicateDays)
+		}
+	}
+
+	// Habit.Tags goes through HabitTag so the join table keeps its timestamp
+	if err := DB.SetupJoinTable(&models.Habit{}, "Tags", &models.HabitTag{}); err != nil {
+		log.Fatal("Failed to set up join table:", err)
+	}
+
 	// Auto migrate the schema
+
 	err = DB.AutoMigrate(
 		&models.User{},
 		&models.HabitCategory{},
 		&models.Habit{},
 		&models.HabitLog{},
+		&models.HabitItem{},
+		&models.HabitTemplate{},
+		&models.Tag{},
+		&models.HabitTag{},
+		&models.Attachment{},
+		&models.Vacation{},
+		&models.StreakFreeze{},
+		&models.HabitPause{},
+		&models.TimerSession{},
+		&models.Goal{},
+		&models.GoalMilestone{},
+		&models.Event{},
+		&models.Achievement{},
+		&models.UserAchievement{},
+		&models.XPEvent{},
+		&models.AuditLog{},
+		&models.Tombstone{},
 	)
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
-	// Seed default categories
+	if backfillLogStatus {
+		DB.Model(&models.HabitLog{}).Where("completed = ?", false).Update("status", models.LogStatusFailed)
+	}
+	if backfillPositions {
+		DB.Model(&models.Habit{}).Where("1 = 1").Update("position", gorm.Expr("id"))
+		DB.Model(&models.HabitCategory{}).Where("1 = 1").Update("position", gorm.Expr("id"))
+	}
+
+	// Seed default categories, the template library and the achievements
 	seedCategories()
+	seedTemplates()
+	seedAchievements()
 
 	log.Println("Database connected and migrated successfully")
 }
@@ -58,10 +108,77 @@ func seedCategories() {
 			{Name: "Mindfulness", Color: "#8b5cf6"},
 		}
 		
-		for _, category := range categories {
+		for i, category := range categories {
+			category.Position = i
 			DB.Create(&category)
 		}
 		
 		log.Println("Default categories seeded")
 	}
 }
+
+func seedTemplates() {
+	var count int64
+	DB.Model(&models.HabitTemplate{}).Where("user_id IS NULL").Count(&count)
+	
+	if count == 0 {
+		templates := []struct {
+			Category string
+			Template models.HabitTemplate
+		}{
+			{"Health", models.HabitTemplate{Name: "Drink water", Description: "Stay hydrated through the day", Unit: "glasses", TargetPerDay: 8, Color: "#0ea5e9", Schedule: "daily"}},
+			{"Health", models.HabitTemplate{Name: "Exercise", Description: "Any workout that gets your heart rate up", Unit: models.UnitMinutes, TargetPerDay: 30, Color: "#10b981", Schedule: "3x per week"}},
+			{"Health", models.HabitTemplate{Name: "Walk 10,000 steps", Unit: "steps", TargetPerDay: 10000, Color: "#22c55e", Schedule: "daily"}},
+			{"Health", models.HabitTemplate{Name: "Quit smoking", Description: "Log a relapse whenever you smoke", Kind: models.HabitKindQuit, Color: "#ef4444", Schedule: "daily"}},
+			{"Productivity", models.HabitTemplate{Name: "Deep work", Description: "Focused work without distractions", Unit: models.UnitMinutes, TargetPerDay: 120, Color: "#6366f1", Schedule: "weekdays"}},
+			{"Productivity", models.HabitTemplate{Name: "Morning routine", Color: "#f97316", Schedule: "daily", Items: models.StringList{"Make the bed", "Drink a glass of water", "Stretch", "Plan the day"}}},
+			{"Learning", models.HabitTemplate{Name: "Read", Unit: "pages", TargetPerDay: 10, Color: "#f59e0b", Schedule: "daily"}},
+			{"Learning", models.HabitTemplate{Name: "Practice a language", Unit: models.UnitMinutes, TargetPerDay: 15, Color: "#eab308", Schedule: "daily"}},
+			{"Mindfulness", models.HabitTemplate{Name: "Meditate", Unit: models.UnitMinutes, TargetPerDay: 10, Color: "#8b5cf6", Schedule: "daily"}},
+			{"Mindfulness", models.HabitTemplate{Name: "Journal", Description: "Write down a few thoughts about the day", Color: "#a855f7", Schedule: "daily"}},
+		}
+		
+		for _, seed := range templates {
+			template := seed.Template
+			var category models.HabitCategory
+			if err := DB.Where("name = ?", seed.Category).First(&category).Error; err == nil {
+				template.CategoryID = &category.ID
+			}
+			if template.Kind == "" {
+				template.Kind = models.HabitKindBuild
+			}
+			if template.TargetPerDay == 0 {
+				template.TargetPerDay = 1
+			}
+			DB.Create(&template)
+		}
+		
+		log.Println("Template library seeded")
+	}
+}
+
+// seedAchievements keeps the achievement rows in step with this list on
+// every start, matching them by code. Adding a badge for an existing rule
+// only takes a new entry here.
+func seedAchievements() {
+	achievements := []models.Achievement{
+		{Code: "first_check_in", Name: "First check-in", Description: "Complete a habit for the first time", Rule: models.AchievementRuleCompletedLogs, Threshold: 1},
+		{Code: "streak_7", Name: "One week strong", Description: "Reach a 7-day streak", Rule: models.AchievementRuleStreak, Threshold: 7},
+		{Code: "streak_30", Name: "Monthly momentum", Description: "Reach a 30-day streak", Rule: models.AchievementRuleStreak, Threshold: 30},
+		{Code: "streak_100", Name: "Centurion", Description: "Reach a 100-day streak", Rule: models.AchievementRuleStreak, Threshold: 100},
+		{Code: "streak_365", Name: "Year of habit", Description: "Reach a 365-day streak", Rule: models.AchievementRuleStreak, Threshold: 365},
+		{Code: "perfect_week", Name: "Perfect week", Description: "Keep a habit up from Monday to Sunday without missing a day", Rule: models.AchievementRulePerfectWeek, Threshold: 1},
+		{Code: "category_mastery", Name: "Category master", Description: "Keep up every habit of a category for 30 days in a row", Rule: models.AchievementRuleCategoryMastery, Threshold: 30},
+		{Code: "comeback", Name: "Comeback", Description: "Complete a habit again after missing it for a week or more", Rule: models.AchievementRuleComeback, Threshold: 7},
+	}
+	
+	for i := range achievements {
+		achievements[i].Position = i
+	}
+	if err := DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "code"}},
+		DoUpdates: clause.AssignmentColumns([]string{"name", "description", "rule", "threshold", "position", "updated_at"}),
+	}).Create(&achievements).Error; err != nil {
+		log.Println("Failed to seed achievements:", err)
+	}
+}
@@ -0,0 +1,48 @@
+// Package events records things the server noticed on a user's behalf, such
+// as a goal being achieved, for clients to pick up.
+package events
+
+import (
+	"encoding/json"
+
+	"gorm.io/gorm"
+	"habit-tracker/models"
+)
+
+const (
+	GoalMilestone   = "goal_milestone"
+	GoalAchieved    = "goal_achieved"
+	GoalUnreachable = "goal_unreachable"
+
+	AchievementEarned = "achievement_earned"
+)
+
+// Entry describes a single event to be emitted
+type Entry struct {
+	Type       string
+	EntityType string
+	EntityID   uint
+	Data       interface{}
+}
+
+// Emit stores an event for userID using tx, so it is only seen once the
+// change that caused it commits. entry.Data is stored as JSON.
+func Emit(tx *gorm.DB, userID uint, entry Entry) error {
+	event := models.Event{
+		UserID:     userID,
+		Type:       entry.Type,
+		EntityType: entry.EntityType,
+	}
+	if entry.EntityID != 0 {
+		id := entry.EntityID
+		event.EntityID = &id
+	}
+	if entry.Data != nil {
+		data, err := json.Marshal(entry.Data)
+		if err != nil {
+			return err
+		}
+		event.Data = data
+	}
+	return tx.Create(&event).Error
+}
@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"habit-tracker/apperror"
+	"habit-tracker/audit"
+	"habit-tracker/database"
+	"habit-tracker/events"
+	"habit-tracker/models"
+	"habit-tracker/stats"
+)
+
+// AchievementStatus is an achievement with when the user earned it, nil
+// while it is still locked
+type AchievementStatus struct {
+	models.Achievement
+	EarnedAt *time.Time `json:"earned_at"`
+	HabitID  *uint      `json:"habit_id"`
+}
+
+// achievementEvent is the data of the event emitted for a new badge
+type achievementEvent struct {
+	AchievementID uint   `json:"achievement_id"`
+	Code          string `json:"code"`
+	Name          string `json:"name"`
+	HabitID       *uint  `json:"habit_id,omitempty"`
+}
+
+// achievementRule reports whether the user meets a rule with the given
+// threshold, and the habit that met it for rules about a single habit
+type achievementRule func(check *achievementCheck, threshold int) (bool, *uint, error)
+
+// achievementRules maps Achievement.Rule to its check. A badge with a rule
+// that is not listed here is never awarded.
+var achievementRules = map[string]achievementRule{
+	models.AchievementRuleCompletedLogs:   ruleCompletedLogs,
+	models.AchievementRuleStreak:          ruleStreak,
+	models.AchievementRulePerfectWeek:     rulePerfectWeek,
+	models.AchievementRuleCategoryMastery: ruleCategoryMastery,
+	models.AchievementRuleComeback:        ruleComeback,
+}
+
+// achievementCheck is what the rules look at: a user and the habits whose
+// logs just changed
+type achievementCheck struct {
+	tx        *gorm.DB
+	userID    uint
+	habits    []models.Habit
+	today     time.Time
+	calendars *calendarCache
+	// categoryHabits holds the active habits of each category read so far
+	categoryHabits map[uint][]models.Habit
+}
+
+// GetAchievements lists every achievement in order with when the user
+// earned it. earned=true or earned=false only lists earned or locked ones.
+func GetAchievements(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	earned, err := parseBoolQuery(c, "earned")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var achievements []models.Achievement
+	if err := database.DB.Order("position").Order("id").Find(&achievements).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch achievements"))
+		return
+	}
+
+	var userAchievements []models.UserAchievement
+	if err := database.DB.Where("user_id = ?", userID).Find(&userAchievements).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch achievements"))
+		return
+	}
+	byAchievement := make(map[uint]models.UserAchievement, len(userAchievements))
+	for _, userAchievement := range userAchievements {
+		byAchievement[userAchievement.AchievementID] = userAchievement
+	}
+
+	result := []AchievementStatus{}
+	for _, achievement := range achievements {
+		status := AchievementStatus{Achievement: achievement}
+		if userAchievement, ok := byAchievement[achievement.ID]; ok {
+			earnedAt := userAchievement.EarnedAt
+			status.EarnedAt = &earnedAt
+			status.HabitID = userAchievement.HabitID
+		}
+		if earned != nil && *earned != (status.EarnedAt != nil) {
+			continue
+		}
+		result = append(result, status)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Achievements retrieved successfully",
+		"data":    result,
+	})
+}
+
+// awardAchievements checks the achievements the user has not earned yet
+// after the logs of the given habits changed, and records and announces
+// the ones now met
+func awardAchievements(tx *gorm.DB, habits []models.Habit, calendars *calendarCache, now time.Time) error {
+	if len(habits) == 0 {
+		return nil
+	}
+	userID := habits[0].UserID
+
+	var achievements []models.Achievement
+	if err := tx.Where("id NOT IN (?)", tx.Model(&models.UserAchievement{}).Select("achievement_id").Where("user_id = ?", userID)).
+		Order("position").Order("id").Find(&achievements).Error; err != nil {
+		return err
+	}
+
+	check := &achievementCheck{
+		tx:             tx,
+		userID:         userID,
+		habits:         habits,
+		today:          startOfDay(now),
+		calendars:      calendars,
+		categoryHabits: make(map[uint][]models.Habit),
+	}
+	for _, achievement := range achievements {
+		rule, ok := achievementRules[achievement.Rule]
+		if !ok {
+			log.Printf("Achievement %s has unknown rule %q", achievement.Code, achievement.Rule)
+			continue
+		}
+		met, habitID, err := rule(check, achievement.Threshold)
+		if err != nil {
+			return err
+		}
+		if !met {
+			continue
+		}
+
+		// A concurrent write may have awarded it first; announce it once
+		earned := models.UserAchievement{UserID: userID, AchievementID: achievement.ID, HabitID: habitID, EarnedAt: now}
+		created := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&earned)
+		if created.Error != nil {
+			return created.Error
+		}
+		if created.RowsAffected == 0 {
+			continue
+		}
+		if err := events.Emit(tx, userID, events.Entry{
+			Type:       events.AchievementEarned,
+			EntityType: audit.EntityAchievement,
+			EntityID:   achievement.ID,
+			Data:       achievementEvent{AchievementID: achievement.ID, Code: achievement.Code, Name: achievement.Name, HabitID: habitID},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eachHabit runs met on the calendar of every changed habit until one
+// meets the rule
+func (check *achievementCheck) eachHabit(met func(habit *models.Habit, days []CalendarDay) bool) (bool, *uint, error) {
+	for i := range check.habits {
+		habit := &check.habits[i]
+		days, err := check.calendars.lifetime(habit)
+		if err != nil {
+			return false, nil, err
+		}
+		if met(habit, days) {
+			id := habit.ID
+			return true, &id, nil
+		}
+	}
+	return false, nil, nil
+}
+
+func ruleCompletedLogs(check *achievementCheck, threshold int) (bool, *uint, error) {
+	var completed int64
+	err := check.tx.Model(&models.HabitLog{}).
+		Where("user_id = ? AND status = ?", check.userID, models.LogStatusCompleted).
+		Count(&completed).Error
+	return completed >= int64(threshold), nil, err
+}
+
+func ruleStreak(check *achievementCheck, threshold int) (bool, *uint, error) {
+	return check.eachHabit(func(habit *models.Habit, days []CalendarDay) bool {
+		return stats.Summarize(calendarStates(days)).LongestStreak >= threshold
+	})
+}
+
+// rulePerfectWeek counts the Monday-to-Sunday weeks, all within the habit's
+// history and over, with at least one completed day and none missed
+func rulePerfectWeek(check *achievementCheck, threshold int) (bool, *uint, error) {
+	return check.eachHabit(func(habit *models.Habit, days []CalendarDay) bool {
+		start := startOfDay(habit.StartDate)
+		perfect := 0
+		for i := range days {
+			date := start.AddDate(0, 0, i)
+			if date.Weekday() != time.Monday || i+7 > len(days) || date.AddDate(0, 0, 7).After(check.today) {
+				continue
+			}
+			summary := stats.Summarize(calendarStates(days[i : i+7]))
+			if summary.MissedDays == 0 && summary.CompletedDays > 0 {
+				perfect++
+			}
+		}
+		return perfect >= threshold
+	})
+}
+
+// ruleCategoryMastery looks at the categories of the changed habits. A day
+// counts for a category when none of its current habits was missed and at
+// least one was completed. The whole history is read, since backfilled and
+// synced logs can complete an older streak, and days off inside a streak
+// make it span more than threshold days.
+func ruleCategoryMastery(check *achievementCheck, threshold int) (bool, *uint, error) {
+	var categoryIDs []uint
+	for _, habit := range check.habits {
+		if habit.CategoryID != nil {
+			categoryIDs = append(categoryIDs, *habit.CategoryID)
+		}
+	}
+
+	for _, categoryID := range uniqueIDs(categoryIDs) {
+		habits, err := check.habitsInCategory(categoryID)
+		if err != nil {
+			return false, nil, err
+		}
+
+		calendars := make([][]CalendarDay, 0, len(habits))
+		for i := range habits {
+			days, err := check.calendars.lifetime(&habits[i])
+			if err != nil {
+				return false, nil, err
+			}
+			calendars = append(calendars, days)
+		}
+		if categoryMastered(calendars, threshold) {
+			return true, nil, nil
+		}
+	}
+	return false, nil, nil
+}
+
+// habitsInCategory returns the active habits of one of the user's
+// categories, reading each category once per check
+func (check *achievementCheck) habitsInCategory(categoryID uint) ([]models.Habit, error) {
+	if habits, ok := check.categoryHabits[categoryID]; ok {
+		return habits, nil
+	}
+	var habits []models.Habit
+	if err := check.tx.Where("user_id = ? AND category_id = ? AND archived_at IS NULL", check.userID, categoryID).
+		Find(&habits).Error; err != nil {
+		return nil, err
+	}
+	check.categoryHabits[categoryID] = habits
+	return habits, nil
+}
+
+// categoryMastered reports whether the calendars of a category's habits,
+// combined day by day, hold a streak of at least threshold days
+func categoryMastered(calendars [][]CalendarDay, threshold int) bool {
+	combined := make(map[string]stats.State)
+	var dates []string
+	for _, days := range calendars {
+		for _, day := range days {
+			if state, ok := combined[day.Date]; ok {
+				combined[day.Date] = combineStates(state, day.State)
+			} else {
+				combined[day.Date] = day.State
+				dates = append(dates, day.Date)
+			}
+		}
+	}
+	sort.Strings(dates)
+
+	states := make([]stats.State, len(dates))
+	for i, date := range dates {
+		states[i] = combined[date]
+	}
+	return stats.Summarize(states).LongestStreak >= threshold
+}
+
+// combineStates merges the states of two habits on the same day: a missed
+// habit spoils the day, and a completed one counts only if nothing is
+// pending
+func combineStates(a, b stats.State) stats.State {
+	rank := map[stats.State]int{stats.Neutral: 0, stats.Done: 1, stats.Pending: 2, stats.Missed: 3}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+func ruleComeback(check *achievementCheck, threshold int) (bool, *uint, error) {
+	return check.eachHabit(func(habit *models.Habit, days []CalendarDay) bool {
+		missed := 0
+		for _, day := range days {
+			switch day.State {
+			case stats.Missed:
+				missed++
+			case stats.Done:
+				if missed >= threshold {
+					return true
+				}
+				missed = 0
+			}
+		}
+		return false
+	})
+}
+
+func calendarStates(days []CalendarDay) []stats.State {
+	states := make([]stats.State, len(days))
+	for i, day := range days {
+		states[i] = day.State
+	}
+	return states
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"habit-tracker/models"
+	"habit-tracker/stats"
+)
+
+// testCalendar builds the days of a calendar from start, one per letter of
+// states: D done, M missed, N neutral and P pending
+func testCalendar(start time.Time, states string) []CalendarDay {
+	byLetter := map[rune]stats.State{'D': stats.Done, 'M': stats.Missed, 'N': stats.Neutral, 'P': stats.Pending}
+	days := make([]CalendarDay, 0, len(states))
+	for i, letter := range states {
+		days = append(days, CalendarDay{Date: start.AddDate(0, 0, i).Format("2006-01-02"), State: byLetter[letter]})
+	}
+	return days
+}
+
+// testAchievementCheck checks a single habit starting on start whose
+// calendar is already cached, so the rules read no database
+func testAchievementCheck(start, today time.Time, states string) *achievementCheck {
+	habit := models.Habit{ID: 1, UserID: 1, StartDate: start}
+	calendars := newCalendarCache(nil, today)
+	calendars.calendars[habit.ID] = testCalendar(start, states)
+	return &achievementCheck{userID: 1, habits: []models.Habit{habit}, today: startOfDay(today), calendars: calendars}
+}
+
+type ruleCase struct {
+	name      string
+	states    string
+	threshold int
+	want      bool
+}
+
+func runRuleCases(t *testing.T, rule achievementRule, start, today time.Time, tests []ruleCase) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			met, habitID, err := rule(testAchievementCheck(start, today, tt.states), tt.threshold)
+			if err != nil {
+				t.Fatalf("rule error = %v", err)
+			}
+			if met != tt.want {
+				t.Errorf("met = %v, want %v", met, tt.want)
+			}
+			if met && (habitID == nil || *habitID != 1) {
+				t.Errorf("habitID = %v, want 1", habitID)
+			}
+		})
+	}
+}
+
+func TestRuleStreak(t *testing.T) {
+	runRuleCases(t, ruleStreak, testDay(1), testDay(10), []ruleCase{
+		{"exactly the threshold", "DDDDDDD", 7, true},
+		{"one short", "DDDDDD", 7, false},
+		{"neutral days bridge the streak", "DDDNNDDDD", 7, true},
+		{"missed day breaks the streak", "DDDMDDDD", 7, false},
+		{"pending today does not count", "DDDDDDP", 7, false},
+		{"an earlier streak still counts", "DDDDDDDMD", 7, true},
+	})
+}
+
+func TestRuleComeback(t *testing.T) {
+	runRuleCases(t, ruleComeback, testDay(1), testDay(10), []ruleCase{
+		{"exactly the threshold missed", "DMMMD", 3, true},
+		{"one miss short", "DMMD", 3, false},
+		{"neutral days do not reset the misses", "DMNMNMD", 3, true},
+		{"a done day resets the misses", "MMDMMD", 3, false},
+		{"not back yet", "DMMMP", 3, false},
+	})
+}
+
+func TestRulePerfectWeek(t *testing.T) {
+	monday := testDay(6)
+
+	// The week of May 6 to 12 is over once May 13 starts
+	runRuleCases(t, rulePerfectWeek, monday, testDay(13), []ruleCase{
+		{"all done", "DDDDDDDP", 1, true},
+		{"done and neutral", "DNDNDNDP", 1, true},
+		{"one missed day", "DDDMDDDP", 1, false},
+		{"all neutral", "NNNNNNNP", 1, false},
+		{"only one of the weeks needed", "DDDDDDDP", 2, false},
+	})
+
+	// A week that ends today is not over yet
+	runRuleCases(t, rulePerfectWeek, monday, testDay(12), []ruleCase{
+		{"week ending today", "DDDDDDD", 1, false},
+	})
+
+	// Weeks run Monday to Sunday, so a habit started mid-week waits for the
+	// next Monday
+	runRuleCases(t, rulePerfectWeek, testDay(8), testDay(20), []ruleCase{
+		{"partial first week", "DDDDDDDDDDDDP", 1, true},
+		{"partial first week only", "DDDDDMMMMMMMP", 1, false},
+	})
+}
+
+func TestCombineStates(t *testing.T) {
+	tests := []struct {
+		a, b stats.State
+		want stats.State
+	}{
+		{stats.Done, stats.Neutral, stats.Done},
+		{stats.Neutral, stats.Neutral, stats.Neutral},
+		{stats.Done, stats.Pending, stats.Pending},
+		{stats.Done, stats.Missed, stats.Missed},
+		{stats.Neutral, stats.Missed, stats.Missed},
+		{stats.Pending, stats.Missed, stats.Missed},
+	}
+	for _, tt := range tests {
+		if got := combineStates(tt.a, tt.b); got != tt.want {
+			t.Errorf("combineStates(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := combineStates(tt.b, tt.a); got != tt.want {
+			t.Errorf("combineStates(%v, %v) = %v, want %v", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestCategoryMastered(t *testing.T) {
+	tests := []struct {
+		name      string
+		calendars [][]CalendarDay
+		threshold int
+		want      bool
+	}{
+		{
+			name:      "every habit done",
+			calendars: [][]CalendarDay{testCalendar(testDay(1), "DDD"), testCalendar(testDay(1), "DDD")},
+			threshold: 3,
+			want:      true,
+		},
+		{
+			name:      "a day off of one habit",
+			calendars: [][]CalendarDay{testCalendar(testDay(1), "DDD"), testCalendar(testDay(1), "NDD")},
+			threshold: 3,
+			want:      true,
+		},
+		{
+			name:      "one habit missed",
+			calendars: [][]CalendarDay{testCalendar(testDay(1), "DDD"), testCalendar(testDay(1), "MDD")},
+			threshold: 3,
+			want:      false,
+		},
+		{
+			name:      "a day off of every habit bridges the streak",
+			calendars: [][]CalendarDay{testCalendar(testDay(1), "DNDD"), testCalendar(testDay(1), "DNDD")},
+			threshold: 3,
+			want:      true,
+		},
+		{
+			name:      "today pending for one habit",
+			calendars: [][]CalendarDay{testCalendar(testDay(1), "DDDP"), testCalendar(testDay(1), "DDDD")},
+			threshold: 3,
+			want:      true,
+		},
+		{
+			name:      "today pending is not yet done",
+			calendars: [][]CalendarDay{testCalendar(testDay(1), "DDP"), testCalendar(testDay(1), "DDD")},
+			threshold: 3,
+			want:      false,
+		},
+		{
+			name:      "habit started later",
+			calendars: [][]CalendarDay{testCalendar(testDay(1), "DDDD"), testCalendar(testDay(3), "DD")},
+			threshold: 4,
+			want:      true,
+		},
+		{
+			name:      "no habits",
+			threshold: 1,
+			want:      false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := categoryMastered(tt.calendars, tt.threshold); got != tt.want {
+				t.Errorf("categoryMastered() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleCategoryMastery(t *testing.T) {
+	tests := []struct {
+		name      string
+		states    [2]string
+		threshold int
+		want      bool
+	}{
+		{"streak with a day off inside", [2]string{"DDNDD", "DDNDD"}, 4, true},
+		{"day off of one habit only", [2]string{"DDNDD", "DDDDD"}, 5, true},
+		{"older streak", [2]string{"DDDDMD", "DDDDDD"}, 4, true},
+		{"missed day inside", [2]string{"DDMDD", "DDDDD"}, 3, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			categoryID := uint(5)
+			habits := []models.Habit{
+				{ID: 1, UserID: 1, CategoryID: &categoryID, StartDate: testDay(1)},
+				{ID: 2, UserID: 1, CategoryID: &categoryID, StartDate: testDay(1)},
+			}
+			calendars := newCalendarCache(nil, testDay(10))
+			for i, habit := range habits {
+				calendars.calendars[habit.ID] = testCalendar(habit.StartDate, tt.states[i])
+			}
+			check := &achievementCheck{
+				userID:         1,
+				habits:         habits[:1],
+				today:          testDay(10),
+				calendars:      calendars,
+				categoryHabits: map[uint][]models.Habit{categoryID: habits},
+			}
+
+			met, _, err := ruleCategoryMastery(check, tt.threshold)
+			if err != nil {
+				t.Fatalf("ruleCategoryMastery() error = %v", err)
+			}
+			if met != tt.want {
+				t.Errorf("ruleCategoryMastery() = %v, want %v", met, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"habit-tracker/apperror"
+	"habit-tracker/audit"
+	"habit-tracker/database"
+	"habit-tracker/models"
+	"habit-tracker/stats"
+)
+
+// archivedHabitSortColumns adds the archive date to the habit sort columns.
+// It is only sortable among archived habits, where it is never null.
+var archivedHabitSortColumns = sortableColumns{
+	"id":          kindInt,
+	"name":        kindString,
+	"start_date":  kindTime,
+	"archived_at": kindTime,
+	"created_at":  kindTime,
+}
+
+// ArchiveRequest archives a habit with an optional note on how it went
+type ArchiveRequest struct {
+	Outcome string `json:"outcome" binding:"max=1000"`
+}
+
+// GetArchivedHabits lists archived habits with the stats frozen when they
+// were archived
+func GetArchivedHabits(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	params, err := parseListParams(c, archivedHabitSortColumns, "-archived_at")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	query := database.DB.Where("user_id = ? AND archived_at IS NOT NULL", userID)
+
+	var habits []models.Habit
+	if err := params.apply(query).Preload("Category").Preload("Tags").Find(&habits).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch archived habits"))
+		return
+	}
+
+	habits, page, err := paginate(params, habits)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal("Failed to build pagination cursor", err))
+		return
+	}
+
+	respondList(c, "Archived habits retrieved successfully", habits, page)
+}
+
+// ArchiveHabit retires a habit. It leaves the daily lists and its lifetime
+// stats are frozen as they are now. The body is optional.
+func ArchiveHabit(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := parseIDParam(c, "id", "Invalid habit ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var req ArchiveRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		apperror.Abort(c, apperror.Validation(err))
+		return
+	}
+
+	var habit models.Habit
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&habit).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errHabitNotFound, "Failed to fetch habit"))
+		return
+	}
+
+	if err := checkIfMatch(c, habit.Version); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if habit.ArchivedAt != nil {
+		apperror.Abort(c, apperror.Conflict(apperror.CodeHabitArchived, "Habit is already archived"))
+		return
+	}
+
+	now := time.Now()
+	lifetime, err := lifetimeStats(&habit, now)
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to calculate stats"))
+		return
+	}
+
+	before := habit
+	habit.ArchivedAt = &now
+	habit.Outcome = req.Outcome
+	habit.ArchiveStats = lifetime
+	habit.Version = before.Version + 1
+	columns := []string{"archived_at", "outcome", "archive_stats"}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := versionedUpdate(tx, &before, before.Version, columns, &habit); err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionUpdate,
+			EntityType: audit.EntityHabit,
+			EntityID:   habit.ID,
+			Before:     before,
+			After:      habit,
+		})
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to archive habit"))
+		return
+	}
+
+	c.Header("ETag", versionETag(habit.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Habit archived successfully",
+		"data":    habit,
+	})
+}
+
+// UnarchiveHabit brings an archived habit back and drops its frozen stats
+func UnarchiveHabit(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := parseIDParam(c, "id", "Invalid habit ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var habit models.Habit
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&habit).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errHabitNotFound, "Failed to fetch habit"))
+		return
+	}
+
+	if err := checkIfMatch(c, habit.Version); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if habit.ArchivedAt == nil {
+		apperror.Abort(c, apperror.Conflict(apperror.CodeHabitNotArchived, "Habit is not archived"))
+		return
+	}
+
+	before := habit
+	habit.ArchivedAt = nil
+	habit.Outcome = ""
+	habit.ArchiveStats = nil
+	habit.Version = before.Version + 1
+	columns := []string{"archived_at", "outcome", "archive_stats"}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := versionedUpdate(tx, &before, before.Version, columns, &habit); err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionUpdate,
+			EntityType: audit.EntityHabit,
+			EntityID:   habit.ID,
+			Before:     before,
+			After:      habit,
+		})
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to unarchive habit"))
+		return
+	}
+
+	c.Header("ETag", versionETag(habit.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Habit unarchived successfully",
+		"data":    habit,
+	})
+}
+
+// lifetimeStats summarises a habit from its start date up to now
+func lifetimeStats(habit *models.Habit, now time.Time) (*models.ArchivedStats, error) {
+	days, err := habitCalendar(database.DB, habit, startOfDay(habit.StartDate), startOfDay(now))
+	if err != nil {
+		return nil, err
+	}
+	states := make([]stats.State, len(days))
+	for i, day := range days {
+		states[i] = day.State
+	}
+
+	lifetime := models.ArchivedStats{Summary: stats.Summarize(states)}
+	if err := database.DB.Model(&models.HabitLog{}).
+		Select("COUNT(*), COALESCE(SUM(amount), 0)").
+		Where("habit_id = ?", habit.ID).
+		Row().Scan(&lifetime.TotalLogs, &lifetime.TotalAmount); err != nil {
+		return nil, err
+	}
+	return &lifetime, nil
+}
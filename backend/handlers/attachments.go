@@ -0,0 +1,431 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"gorm.io/gorm"
+	"habit-tracker/apperror"
+	"habit-tracker/audit"
+	"habit-tracker/database"
+	"habit-tracker/models"
+	"habit-tracker/storage"
+)
+
+const (
+	defaultAttachmentMaxBytes = 10 << 20
+	maxAttachmentsPerLog      = 10
+	signedURLExpiry           = 15 * time.Minute
+	thumbnailSize             = 320
+	// maxImagePixels guards thumbnail generation against decompression bombs
+	maxImagePixels = 40_000_000
+)
+
+// attachmentTypes maps the accepted content types, sniffed from the file
+// contents, to the extension used in storage
+var attachmentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+var errAttachmentNotFound = apperror.NotFound(apperror.CodeNotFound, "Attachment not found")
+
+// attachmentMaxBytes is the upload size limit, read from ATTACHMENT_MAX_BYTES
+func attachmentMaxBytes() int64 {
+	limit, err := strconv.ParseInt(os.Getenv("ATTACHMENT_MAX_BYTES"), 10, 64)
+	if err != nil || limit <= 0 {
+		return defaultAttachmentMaxBytes
+	}
+	return limit
+}
+
+// UploadAttachment stores the multipart "file" field and attaches it to a log
+func UploadAttachment(c *gin.Context) {
+	_, habitLog, err := loadHabitLog(c)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var count int64
+	if err := database.DB.Model(&models.Attachment{}).Where("habit_log_id = ?", habitLog.ID).Count(&count).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to count attachments"))
+		return
+	}
+	if count >= maxAttachmentsPerLog {
+		apperror.Abort(c, apperror.Conflict(apperror.CodeAttachmentLimit, fmt.Sprintf("A habit log can have at most %d attachments", maxAttachmentsPerLog)))
+		return
+	}
+
+	upload, err := readUpload(c, attachmentMaxBytes())
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	name, err := randomName()
+	if err != nil {
+		apperror.Abort(c, apperror.Internal("Failed to store file", err))
+		return
+	}
+	attachment := models.Attachment{
+		HabitLogID:  habitLog.ID,
+		UserID:      habitLog.UserID,
+		FileName:    truncateFileName(filepath.Base(upload.fileName)),
+		ContentType: upload.contentType,
+		Size:        int64(len(upload.data)),
+		StorageKey:  fmt.Sprintf("users/%d/logs/%d/%s%s", habitLog.UserID, habitLog.ID, name, upload.ext),
+	}
+
+	ctx := c.Request.Context()
+	if err := storage.Files.Put(ctx, attachment.StorageKey, bytes.NewReader(upload.data), attachment.Size, upload.contentType); err != nil {
+		apperror.Abort(c, apperror.Internal("Failed to store file", err))
+		return
+	}
+	stored := []string{attachment.StorageKey}
+
+	// A thumbnail is a convenience, so a file that cannot be decoded is kept
+	// without one
+	if thumbnail, ok := makeThumbnail(upload.data); ok {
+		key := fmt.Sprintf("users/%d/logs/%d/%s_thumb.jpg", habitLog.UserID, habitLog.ID, name)
+		if err := storage.Files.Put(ctx, key, bytes.NewReader(thumbnail), int64(len(thumbnail)), "image/jpeg"); err == nil {
+			attachment.ThumbnailKey = key
+			stored = append(stored, key)
+		} else {
+			log.Printf("Failed to store thumbnail %s: %v", key, err)
+		}
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("HabitLog").Create(&attachment).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionCreate,
+			EntityType: audit.EntityAttachment,
+			EntityID:   attachment.ID,
+			After:      attachment,
+		})
+	})
+	if err != nil {
+		removeStoredFiles(stored)
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to save attachment"))
+		return
+	}
+
+	if err := signAttachment(ctx, &attachment); err != nil {
+		apperror.Abort(c, apperror.Internal("Failed to sign attachment URL", err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"error":   false,
+		"message": "Attachment uploaded successfully",
+		"data":    attachment,
+	})
+}
+
+// upload is a file read from a multipart request
+type upload struct {
+	data        []byte
+	fileName    string
+	contentType string
+	ext         string
+}
+
+// readUpload reads the multipart "file" field, rejecting files larger than
+// maxBytes and types not in attachmentTypes
+func readUpload(c *gin.Context, maxBytes int64) (*upload, error) {
+	errTooLarge := apperror.New(http.StatusRequestEntityTooLarge, apperror.CodeFileTooLarge, fmt.Sprintf("File must be at most %d bytes", maxBytes))
+
+	// Leave room for the multipart framing around the file
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+1<<20)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, errTooLarge
+		}
+		return nil, apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed").WithField("file", "is required")
+	}
+	if header.Size > maxBytes {
+		return nil, errTooLarge
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return nil, apperror.Internal("Failed to read upload", err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		return nil, apperror.Internal("Failed to read upload", err)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, errTooLarge
+	}
+
+	// Trust the file contents, not the client's Content-Type
+	contentType := http.DetectContentType(data)
+	ext, ok := attachmentTypes[contentType]
+	if !ok {
+		return nil, apperror.New(http.StatusUnsupportedMediaType, apperror.CodeUnsupportedMedia, "File must be a JPEG, PNG, GIF or WebP image or a PDF")
+	}
+	return &upload{data: data, fileName: header.Filename, contentType: contentType, ext: ext}, nil
+}
+
+func GetAttachments(c *gin.Context) {
+	_, habitLog, err := loadHabitLog(c)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var attachments []models.Attachment
+	if err := database.DB.Where("habit_log_id = ?", habitLog.ID).Order("id").Find(&attachments).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch attachments"))
+		return
+	}
+	for i := range attachments {
+		if err := signAttachment(c.Request.Context(), &attachments[i]); err != nil {
+			apperror.Abort(c, apperror.Internal("Failed to sign attachment URL", err))
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Attachments retrieved successfully",
+		"data":    attachments,
+	})
+}
+
+func GetAttachment(c *gin.Context) {
+	attachment, err := loadAttachment(c)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	if err := signAttachment(c.Request.Context(), &attachment); err != nil {
+		apperror.Abort(c, apperror.Internal("Failed to sign attachment URL", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Attachment retrieved successfully",
+		"data":    attachment,
+	})
+}
+
+func DeleteAttachment(c *gin.Context) {
+	attachment, err := loadAttachment(c)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&attachment).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionDelete,
+			EntityType: audit.EntityAttachment,
+			EntityID:   attachment.ID,
+			Before:     attachment,
+		})
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to delete attachment"))
+		return
+	}
+	removeStoredFiles(attachmentKeys([]models.Attachment{attachment}))
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Attachment deleted successfully",
+	})
+}
+
+// ServeFile serves a file of the local storage driver through a signed URL.
+// It is public: the signature is the authorization.
+func ServeFile(c *gin.Context) {
+	local, ok := storage.Files.(*storage.Local)
+	if !ok {
+		apperror.Abort(c, apperror.NotFound(apperror.CodeNotFound, "Resource not found"))
+		return
+	}
+
+	key := c.Param("key")[1:]
+	if !local.Verify(key, c.Query("expires"), c.Query("signature")) {
+		apperror.Abort(c, apperror.Forbidden("Invalid or expired file URL"))
+		return
+	}
+	path, err := local.Path(key)
+	if err != nil {
+		apperror.Abort(c, apperror.NotFound(apperror.CodeNotFound, "Resource not found"))
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		apperror.Abort(c, apperror.NotFound(apperror.CodeNotFound, "Resource not found"))
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=900")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.File(path)
+}
+
+// loadAttachment loads the attachment named by the path, scoped to the log
+func loadAttachment(c *gin.Context) (models.Attachment, error) {
+	var attachment models.Attachment
+	_, habitLog, err := loadHabitLog(c)
+	if err != nil {
+		return attachment, err
+	}
+	id, err := parseIDParam(c, "attachmentId", "Invalid attachment ID")
+	if err != nil {
+		return attachment, err
+	}
+	if err := database.DB.Where("id = ? AND habit_log_id = ?", id, habitLog.ID).First(&attachment).Error; err != nil {
+		return attachment, apperror.FromDB(err, errAttachmentNotFound, "Failed to fetch attachment")
+	}
+	return attachment, nil
+}
+
+func signAttachment(ctx context.Context, attachment *models.Attachment) error {
+	url, err := storage.Files.SignedURL(ctx, attachment.StorageKey, signedURLExpiry)
+	if err != nil {
+		return err
+	}
+	attachment.URL = url
+	if attachment.ThumbnailKey != "" {
+		url, err := storage.Files.SignedURL(ctx, attachment.ThumbnailKey, signedURLExpiry)
+		if err != nil {
+			return err
+		}
+		attachment.ThumbnailURL = url
+	}
+	return nil
+}
+
+// deleteLogAttachments deletes the attachment rows of the given logs and
+// returns their storage keys. The caller removes the files once the
+// transaction has committed.
+func deleteLogAttachments(tx *gorm.DB, logIDs []uint) ([]string, error) {
+	if len(logIDs) == 0 {
+		return nil, nil
+	}
+	var attachments []models.Attachment
+	if err := tx.Where("habit_log_id IN ?", logIDs).Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	if len(attachments) == 0 {
+		return nil, nil
+	}
+	if err := tx.Where("habit_log_id IN ?", logIDs).Delete(&models.Attachment{}).Error; err != nil {
+		return nil, err
+	}
+	return attachmentKeys(attachments), nil
+}
+
+func attachmentKeys(attachments []models.Attachment) []string {
+	var keys []string
+	for _, attachment := range attachments {
+		keys = append(keys, attachment.StorageKey)
+		if attachment.ThumbnailKey != "" {
+			keys = append(keys, attachment.ThumbnailKey)
+		}
+	}
+	return keys
+}
+
+// removeStoredFiles deletes files from storage. Failures only leave orphaned
+// files behind, so they are logged rather than reported to the client.
+func removeStoredFiles(keys []string) {
+	for _, key := range keys {
+		if err := storage.Files.Delete(context.Background(), key); err != nil {
+			log.Printf("Failed to delete stored file %s: %v", key, err)
+		}
+	}
+}
+
+// makeThumbnail scales an image down to fit thumbnailSize and encodes it as
+// JPEG. It reports false for files that are not decodable images.
+func makeThumbnail(data []byte) ([]byte, bool) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width*config.Height > maxImagePixels {
+		return nil, false
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, false
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > thumbnailSize || height > thumbnailSize {
+		if width >= height {
+			height = height * thumbnailSize / width
+			width = thumbnailSize
+		} else {
+			width = width * thumbnailSize / height
+			height = thumbnailSize
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, max(width, 1), max(height, 1)))
+	// JPEG has no alpha channel, so transparent areas become white
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+func randomName() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// truncateFileName keeps the last 255 bytes of name, where the extension
+// is, without splitting a UTF-8 sequence
+func truncateFileName(name string) string {
+	if len(name) <= 255 {
+		return name
+	}
+	start := len(name) - 255
+	for start < len(name) && !utf8.RuneStart(name[start]) {
+		start++
+	}
+	return name[start:]
+}
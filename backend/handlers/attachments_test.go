@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"habit-tracker/apperror"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// uploadContext builds a request context with data as the multipart field
+// named field, sent with the given Content-Type
+func uploadContext(t *testing.T, field, contentType string, data []byte) *gin.Context {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="`+field+`"; filename="photo.png"`)
+	header.Set("Content-Type", contentType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		t.Fatalf("CreatePart: %v", err)
+	}
+	part.Write(data)
+	writer.Close()
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/habits/1/logs/1/attachments", &body)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	return c
+}
+
+func TestReadUpload(t *testing.T) {
+	const maxBytes = 64
+
+	tests := []struct {
+		name        string
+		field       string
+		contentType string
+		data        []byte
+		wantStatus  int
+		wantType    string
+		wantExt     string
+	}{
+		{"png", "file", "image/png", pngHeader, 0, "image/png", ".png"},
+		{"pdf sent as octet stream", "file", "application/octet-stream", []byte("%PDF-1.7\n"), 0, "application/pdf", ".pdf"},
+		{"text claiming to be an image", "file", "image/png", []byte("just some text"), http.StatusUnsupportedMediaType, "", ""},
+		{"html", "file", "text/html", []byte("<html><body></body></html>"), http.StatusUnsupportedMediaType, "", ""},
+		{"at the limit", "file", "image/png", append(pngHeader, make([]byte, maxBytes-len(pngHeader))...), 0, "image/png", ".png"},
+		{"over the limit", "file", "image/png", append(pngHeader, make([]byte, maxBytes-len(pngHeader)+1)...), http.StatusRequestEntityTooLarge, "", ""},
+		{"request body over the limit", "file", "image/png", append(pngHeader, make([]byte, 2<<20)...), http.StatusRequestEntityTooLarge, "", ""},
+		{"missing file", "other", "image/png", pngHeader, http.StatusBadRequest, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upload, err := readUpload(uploadContext(t, tt.field, tt.contentType, tt.data), maxBytes)
+			if tt.wantStatus != 0 {
+				var appErr *apperror.Error
+				if !errors.As(err, &appErr) {
+					t.Fatalf("readUpload() error = %v, want status %d", err, tt.wantStatus)
+				}
+				if appErr.Status != tt.wantStatus {
+					t.Errorf("status = %d, want %d", appErr.Status, tt.wantStatus)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readUpload() error = %v", err)
+			}
+			if upload.contentType != tt.wantType || upload.ext != tt.wantExt {
+				t.Errorf("type = %q %q, want %q %q", upload.contentType, upload.ext, tt.wantType, tt.wantExt)
+			}
+			if !bytes.Equal(upload.data, tt.data) {
+				t.Errorf("data has %d bytes, want %d", len(upload.data), len(tt.data))
+			}
+			if upload.fileName != "photo.png" {
+				t.Errorf("fileName = %q, want %q", upload.fileName, "photo.png")
+			}
+		})
+	}
+}
+
+func TestTruncateFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"short", "photo.jpg", "photo.jpg"},
+		{"exactly 255 bytes", strings.Repeat("a", 251) + ".jpg", strings.Repeat("a", 251) + ".jpg"},
+		{"keeps the extension", strings.Repeat("a", 300) + ".jpg", strings.Repeat("a", 251) + ".jpg"},
+		// 258 bytes: cutting 255 from the end would split the second rune
+		{"multibyte", strings.Repeat("é", 128) + ".j", strings.Repeat("é", 126) + ".j"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateFileName(tt.in)
+			if got != tt.want {
+				t.Errorf("truncateFileName() = %q, want %q", got, tt.want)
+			}
+			if !utf8.ValidString(got) || len(got) > 255 {
+				t.Errorf("truncateFileName() = %d bytes, valid UTF-8 %v", len(got), utf8.ValidString(got))
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"habit-tracker/apperror"
+	"habit-tracker/database"
+	"habit-tracker/models"
+)
+
+var auditSortColumns = sortableColumns{
+	"id":         kindInt,
+	"created_at": kindTime,
+}
+
+func GetAuditLogs(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	query := database.DB.Where("actor_id = ?", userID)
+	listAuditLogs(c, query)
+}
+
+func GetAllAuditLogs(c *gin.Context) {
+	query := database.DB.Model(&models.AuditLog{})
+	actorID, err := parseIDQuery(c, "actor_id", "Invalid actor ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if actorID != nil {
+		query = query.Where("actor_id = ?", *actorID)
+	}
+	listAuditLogs(c, query)
+}
+
+func listAuditLogs(c *gin.Context, query *gorm.DB) {
+	params, err := parseListParams(c, auditSortColumns, "-created_at")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if entityType := c.Query("entity_type"); entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+	entityID, err := parseIDQuery(c, "entity_id", "Invalid entity ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if entityID != nil {
+		query = query.Where("entity_id = ?", *entityID)
+	}
+
+	var logs []models.AuditLog
+	if err := params.apply(query).Find(&logs).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch audit logs"))
+		return
+	}
+
+	logs, page, err := paginate(params, logs)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal("Failed to build pagination cursor", err))
+		return
+	}
+
+	respondList(c, "Audit logs retrieved successfully", logs, page)
+}
@@ -8,10 +8,15 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"habit-tracker/apperror"
+	"habit-tracker/audit"
 	"habit-tracker/database"
 	"habit-tracker/models"
 )
 
+var errUserNotFound = apperror.NotFound(apperror.CodeUserNotFound, "User not found")
+
 type RegisterRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=6"`
@@ -22,39 +27,40 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
 type AuthResponse struct {
 	Token string      `json:"token"`
 	User  models.User `json:"user"`
 }
 
+// Profile is the current user with the level their XP reaches
+type Profile struct {
+	models.User
+	Level LevelProgress `json:"level"`
+}
+
 func Register(c *gin.Context) {
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   true,
-			"message": "Invalid request data",
-			"details": err.Error(),
-		})
+		apperror.Abort(c, apperror.Validation(err))
 		return
 	}
 
 	// Check if user already exists
 	var existingUser models.User
 	if err := database.DB.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
-		c.JSON(http.StatusConflict, gin.H{
-			"error":   true,
-			"message": "User with this email already exists",
-		})
+		apperror.Abort(c, apperror.Conflict(apperror.CodeEmailTaken, "User with this email already exists"))
 		return
 	}
 
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   true,
-			"message": "Failed to hash password",
-		})
+		apperror.Abort(c, apperror.Internal("Failed to hash password", err))
 		return
 	}
 
@@ -64,21 +70,32 @@ func Register(c *gin.Context) {
 		PasswordHash: string(hashedPassword),
 	}
 
-	if err := database.DB.Create(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   true,
-			"message": "Failed to create user",
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			ActorID:    user.ID,
+			Action:     audit.ActionCreate,
+			EntityType: audit.EntityUser,
+			EntityID:   user.ID,
+			After:      user,
 		})
+	})
+	if err != nil {
+		appErr := apperror.FromDB(err, nil, "Failed to create user")
+		if appErr.Code == apperror.CodeDuplicateEntry {
+			// Lost a race with a concurrent registration for the same email
+			appErr = apperror.Conflict(apperror.CodeEmailTaken, "User with this email already exists")
+		}
+		apperror.Abort(c, appErr)
 		return
 	}
 
 	// Generate JWT token
 	token, err := generateJWT(user.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   true,
-			"message": "Failed to generate token",
-		})
+		apperror.Abort(c, apperror.Internal("Failed to generate token", err))
 		return
 	}
 
@@ -95,40 +112,39 @@ func Register(c *gin.Context) {
 func Login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   true,
-			"message": "Invalid request data",
-			"details": err.Error(),
-		})
+		apperror.Abort(c, apperror.Validation(err))
 		return
 	}
 
 	// Find user by email
 	var user models.User
 	if err := database.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error":   true,
-			"message": "Invalid email or password",
-		})
+		recordLoginFailure(c, 0, req.Email)
+		apperror.Abort(c, apperror.Unauthorized(apperror.CodeInvalidCredentials, "Invalid email or password"))
 		return
 	}
 
 	// Check password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error":   true,
-			"message": "Invalid email or password",
-		})
+		recordLoginFailure(c, user.ID, req.Email)
+		apperror.Abort(c, apperror.Unauthorized(apperror.CodeInvalidCredentials, "Invalid email or password"))
 		return
 	}
 
 	// Generate JWT token
 	token, err := generateJWT(user.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   true,
-			"message": "Failed to generate token",
-		})
+		apperror.Abort(c, apperror.Internal("Failed to generate token", err))
+		return
+	}
+
+	if err := audit.Record(database.DB, c, audit.Entry{
+		ActorID:    user.ID,
+		Action:     audit.ActionLogin,
+		EntityType: audit.EntityUser,
+		EntityID:   user.ID,
+	}); err != nil {
+		apperror.Abort(c, apperror.Internal("Failed to record login", err))
 		return
 	}
 
@@ -145,26 +161,81 @@ func Login(c *gin.Context) {
 func GetProfile(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error":   true,
-			"message": "User not authenticated",
-		})
+		apperror.Abort(c, apperror.Unauthorized(apperror.CodeUnauthorized, "User not authenticated"))
 		return
 	}
 
 	var user models.User
 	if err := database.DB.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":   true,
-			"message": "User not found",
-		})
+		apperror.Abort(c, apperror.FromDB(err, errUserNotFound, "Failed to fetch user"))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"error":   false,
 		"message": "Profile retrieved successfully",
-		"data":    user,
+		"data":    Profile{User: user, Level: levelFor(user.XP)},
+	})
+}
+
+func ChangePassword(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(c, apperror.Validation(err))
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errUserNotFound, "Failed to fetch user"))
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.CurrentPassword)); err != nil {
+		apperror.Abort(c, apperror.Unauthorized(apperror.CodeInvalidCredentials, "Current password is incorrect"))
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal("Failed to hash password", err))
+		return
+	}
+
+	// Password hashes are never written to the audit log
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("password_hash", string(hashedPassword)).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionPasswordChange,
+			EntityType: audit.EntityUser,
+			EntityID:   user.ID,
+		})
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to change password"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Password changed successfully",
+	})
+}
+
+// recordLoginFailure is best effort; a failed write must not change the response.
+// A failure on an existing account is recorded with the account as actor so
+// its owner sees it in their audit log.
+func recordLoginFailure(c *gin.Context, userID uint, email string) {
+	audit.Record(database.DB, c, audit.Entry{
+		ActorID:    userID,
+		Action:     audit.ActionLoginFailed,
+		EntityType: audit.EntityUser,
+		EntityID:   userID,
+		After:      gin.H{"email": email},
 	})
 }
 
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"habit-tracker/apperror"
+	"habit-tracker/models"
+)
+
+// defaultBackfillDays is used when LOG_BACKFILL_DAYS is unset or invalid
+const defaultBackfillDays = 30
+
+// backfillDays is how many days back logs may be created or edited, read
+// from LOG_BACKFILL_DAYS. A negative value removes the limit.
+func backfillDays() int {
+	days, err := strconv.Atoi(os.Getenv("LOG_BACKFILL_DAYS"))
+	if err != nil {
+		return defaultBackfillDays
+	}
+	return days
+}
+
+// startOfDay truncates t to midnight in the server's time zone
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.In(time.Local).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+}
+
+// checkBackfillWindow rejects dates older than the backfill policy allows
+func checkBackfillWindow(field string, date time.Time, now time.Time) error {
+	days := backfillDays()
+	if days < 0 {
+		return nil
+	}
+	if startOfDay(date).Before(startOfDay(now).AddDate(0, 0, -days)) {
+		message := fmt.Sprintf("Logs older than %d days cannot be created or edited", days)
+		return apperror.New(http.StatusUnprocessableEntity, apperror.CodeBackfillWindow, message).WithField(field, message)
+	}
+	return nil
+}
+
+// validateLogDate checks a log date against today, the habit's start date
+// and the backfill policy. field names the date in error details.
+func validateLogDate(field string, habit *models.Habit, date time.Time, now time.Time) error {
+	appErr := apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed")
+	day := startOfDay(date)
+	if day.After(startOfDay(now)) {
+		return appErr.WithField(field, "cannot be in the future")
+	}
+	if day.Before(startOfDay(habit.StartDate)) {
+		return appErr.WithField(field, "cannot be before the habit's start date")
+	}
+	return checkBackfillWindow(field, date, now)
+}
+
+// applyLogStatus keeps Completed and Status consistent. When the client set
+// the status it decides completed; otherwise the status follows completed,
+// keeping an existing skip. Only skipped logs keep a skip reason.
+func applyLogStatus(habitLog *models.HabitLog, statusSet bool) {
+	if statusSet && habitLog.Status != "" {
+		habitLog.Completed = habitLog.Status == models.LogStatusCompleted
+	} else if habitLog.Completed {
+		habitLog.Status = models.LogStatusCompleted
+	} else if habitLog.Status != models.LogStatusSkipped {
+		habitLog.Status = models.LogStatusFailed
+	}
+
+	if habitLog.Status != models.LogStatusSkipped {
+		habitLog.SkipReason = ""
+	}
+}
+
+// logStatusColumns makes sure completed and status are written together
+func logStatusColumns(columns []string) ([]string, bool) {
+	var hasStatus, hasCompleted bool
+	for _, column := range columns {
+		switch column {
+		case "status":
+			hasStatus = true
+		case "completed":
+			hasCompleted = true
+		}
+	}
+	if hasStatus && !hasCompleted {
+		columns = append(columns, "completed")
+	}
+	if hasCompleted && !hasStatus {
+		columns = append(columns, "status")
+	}
+	if (hasStatus || hasCompleted) && !containsColumn(columns, "skip_reason") {
+		columns = append(columns, "skip_reason")
+	}
+	return columns, hasStatus
+}
@@ -1,50 +1,108 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
-	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"habit-tracker/apperror"
+	"habit-tracker/audit"
 	"habit-tracker/database"
 	"habit-tracker/models"
 )
 
+var errCategoryNotFound = apperror.NotFound(apperror.CodeCategoryNotFound, "Category not found")
+
+// categoryColumns are the columns a client may write with PUT or PATCH
+var categoryColumns = patchColumns(CategoryPatch{})
+
+var categorySortColumns = sortableColumns{
+	"id":         kindInt,
+	"name":       kindString,
+	"position":   kindInt,
+	"created_at": kindTime,
+	"updated_at": kindTime,
+}
+
 func GetCategories(c *gin.Context) {
+	params, err := parseListParams(c, categorySortColumns, "position")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	query := database.DB.Model(&models.HabitCategory{})
+	parentID, err := parseIDQuery(c, "parent_id", "Invalid category ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if parentID != nil {
+		query = query.Where("parent_id = ?", *parentID)
+	}
+	if search := strings.TrimSpace(c.Query("q")); search != "" {
+		query = query.Where("name LIKE ?", "%"+escapeLike(search)+"%")
+	}
+
 	var categories []models.HabitCategory
-	if err := database.DB.Find(&categories).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   true,
-			"message": "Failed to fetch categories",
-		})
+	if err := params.apply(query).Find(&categories).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch categories"))
+		return
+	}
+
+	categories, page, err := paginate(params, categories)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal("Failed to build pagination cursor", err))
+		return
+	}
+
+	respondList(c, "Categories retrieved successfully", categories, page)
+}
+
+// GetCategoryTree returns all categories as a forest of root categories
+// with their children nested, each level in position order
+func GetCategoryTree(c *gin.Context) {
+	var categories []models.HabitCategory
+	if err := database.DB.Order("position").Order("id").Find(&categories).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch categories"))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"error":   false,
 		"message": "Categories retrieved successfully",
-		"data":    categories,
+		"data":    buildCategoryTree(categories),
 	})
 }
 
 func CreateCategory(c *gin.Context) {
 	var category models.HabitCategory
 	if err := c.ShouldBindJSON(&category); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   true,
-			"message": "Invalid request data",
-			"details": err.Error(),
-		})
+		apperror.Abort(c, apperror.Validation(err))
 		return
 	}
 
-	if err := database.DB.Create(&category).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   true,
-			"message": "Failed to create category",
-		})
+	category.ID, category.Version, category.ClientID = 0, 1, nil
+	if err := validateCategory(&category); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkCategoryParent(tx, &category); err != nil {
+			return err
+		}
+		return insertCategory(tx, c, &category)
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to create category"))
 		return
 	}
 
+	c.Header("ETag", versionETag(category.Version))
 	c.JSON(http.StatusCreated, gin.H{
 		"error":   false,
 		"message": "Category created successfully",
@@ -53,41 +111,58 @@ func CreateCategory(c *gin.Context) {
 }
 
 func UpdateCategory(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	id, err := parseIDParam(c, "id", "Invalid category ID")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   true,
-			"message": "Invalid category ID",
-		})
+		apperror.Abort(c, err)
 		return
 	}
 
 	var category models.HabitCategory
-	if err := database.DB.First(&category, uint(id)).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":   true,
-			"message": "Category not found",
-		})
+	if err := database.DB.First(&category, id).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errCategoryNotFound, "Failed to fetch category"))
+		return
+	}
+
+	if err := checkIfMatch(c, category.Version); err != nil {
+		apperror.Abort(c, err)
 		return
 	}
 
+	before := category
 	if err := c.ShouldBindJSON(&category); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   true,
-			"message": "Invalid request data",
-			"details": err.Error(),
-		})
+		apperror.Abort(c, apperror.Validation(err))
 		return
 	}
 
-	if err := database.DB.Save(&category).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   true,
-			"message": "Failed to update category",
+	// Bookkeeping fields are never taken from the client
+	category.ID = before.ID
+	category.CreatedAt = before.CreatedAt
+	category.Version = before.Version + 1
+	if err := validateCategory(&category); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkCategoryParent(tx, &category); err != nil {
+			return err
+		}
+		if err := versionedUpdate(tx, &before, before.Version, categoryColumns, &category); err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionUpdate,
+			EntityType: audit.EntityCategory,
+			EntityID:   category.ID,
+			Before:     before,
+			After:      category,
 		})
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to update category"))
 		return
 	}
 
+	c.Header("ETag", versionETag(category.Version))
 	c.JSON(http.StatusOK, gin.H{
 		"error":   false,
 		"message": "Category updated successfully",
@@ -95,21 +170,101 @@ func UpdateCategory(c *gin.Context) {
 	})
 }
 
-func DeleteCategory(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+func PatchCategory(c *gin.Context) {
+	id, err := parseIDParam(c, "id", "Invalid category ID")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   true,
-			"message": "Invalid category ID",
+		apperror.Abort(c, err)
+		return
+	}
+
+	var category models.HabitCategory
+	if err := database.DB.First(&category, id).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errCategoryNotFound, "Failed to fetch category"))
+		return
+	}
+
+	if err := checkIfMatch(c, category.Version); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	before := category
+	columns, err := bindMergePatch(c, &category, CategoryPatch{})
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if err := validateCategory(&category); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if len(columns) > 0 {
+		category.Version = before.Version + 1
+		err = database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := checkCategoryParent(tx, &category); err != nil {
+				return err
+			}
+			if err := versionedUpdate(tx, &before, before.Version, columns, &category); err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionUpdate,
+				EntityType: audit.EntityCategory,
+				EntityID:   category.ID,
+				Before:     before,
+				After:      category,
+			})
 		})
+		if err != nil {
+			apperror.Abort(c, apperror.FromDB(err, nil, "Failed to update category"))
+			return
+		}
+	}
+
+	database.DB.First(&category, id)
+
+	c.Header("ETag", versionETag(category.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Category updated successfully",
+		"data":    category,
+	})
+}
+
+// DeleteCategory deletes a category. Its children move up to its parent,
+// and so do its habits; a root category still used by habits cannot be
+// deleted.
+func DeleteCategory(c *gin.Context) {
+	id, err := parseIDParam(c, "id", "Invalid category ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var category models.HabitCategory
+	if err := database.DB.First(&category, id).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errCategoryNotFound, "Failed to fetch category"))
+		return
+	}
+
+	if err := checkIfMatch(c, category.Version); err != nil {
+		apperror.Abort(c, err)
 		return
 	}
 
-	if err := database.DB.Delete(&models.HabitCategory{}, uint(id)).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   true,
-			"message": "Failed to delete category",
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := deleteCategory(tx, c, &category); err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionDelete,
+			EntityType: audit.EntityCategory,
+			EntityID:   category.ID,
+			Before:     category,
 		})
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to delete category"))
 		return
 	}
 
@@ -118,3 +273,206 @@ func DeleteCategory(c *gin.Context) {
 		"message": "Category deleted successfully",
 	})
 }
+
+// validateCategory checks the rules the database cannot express
+func validateCategory(category *models.HabitCategory) error {
+	appErr := apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed")
+	if strings.TrimSpace(category.Name) == "" {
+		appErr = appErr.WithField("name", "is required")
+	}
+	if strings.TrimSpace(category.Color) == "" {
+		appErr = appErr.WithField("color", "is required")
+	}
+	if len(appErr.Fields) > 0 {
+		return appErr
+	}
+	return nil
+}
+
+// insertCategory creates a category after all others
+func insertCategory(tx *gorm.DB, c *gin.Context, category *models.HabitCategory) error {
+	var last *int
+	if err := tx.Model(&models.HabitCategory{}).Select("MAX(position)").Scan(&last).Error; err != nil {
+		return err
+	}
+	category.Position = 0
+	if last != nil {
+		category.Position = *last + 1
+	}
+
+	if err := tx.Omit(clause.Associations).Create(category).Error; err != nil {
+		return err
+	}
+	return audit.Record(tx, c, audit.Entry{
+		Action:     audit.ActionCreate,
+		EntityType: audit.EntityCategory,
+		EntityID:   category.ID,
+		After:      category,
+	})
+}
+
+// checkCategoryParent rejects a parent that does not exist or that would
+// make the category its own ancestor. Moving a category moves its subtree.
+// It runs in the transaction that saves the category and locks it and its
+// new ancestors, so two concurrent moves cannot form a cycle between them.
+func checkCategoryParent(tx *gorm.DB, category *models.HabitCategory) error {
+	if category.ParentID == nil {
+		return nil
+	}
+	appErr := apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed")
+	if *category.ParentID == category.ID {
+		return appErr.WithField("parent_id", "cannot be the category itself")
+	}
+
+	if category.ID != 0 {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.HabitCategory{}, category.ID).Error; err != nil {
+			return err
+		}
+	}
+
+	seen := make(map[uint]bool)
+	for id := category.ParentID; id != nil && !seen[*id]; {
+		if *id == category.ID {
+			return appErr.WithField("parent_id", "cannot be one of the category's descendants")
+		}
+		seen[*id] = true
+
+		var parent models.HabitCategory
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "parent_id").First(&parent, *id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) && id == category.ParentID {
+			return appErr.WithField("parent_id", "unknown category")
+		}
+		if err != nil {
+			return err
+		}
+		id = parent.ParentID
+	}
+	return nil
+}
+
+// deleteCategory deletes a category and leaves a tombstone. Its children
+// and habits move to its parent; without a parent, habits block the delete.
+// Every moved row gets a new version and an audit entry.
+func deleteCategory(tx *gorm.DB, c *gin.Context, category *models.HabitCategory) error {
+	var habits []models.Habit
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("category_id = ?", category.ID).
+		Order("user_id").Order("id").Find(&habits).Error; err != nil {
+		return err
+	}
+	if category.ParentID == nil && len(habits) > 0 {
+		return apperror.Conflict(apperror.CodeResourceInUse, "Category is still used by habits")
+	}
+
+	// Categories are shared, so the habits can belong to several users
+	var userIDs []uint
+	habitIDs := make(map[uint][]uint)
+	for _, before := range habits {
+		habit := before
+		habit.CategoryID = category.ParentID
+		habit.Version = before.Version + 1
+		if err := versionedUpdate(tx, &before, before.Version, []string{"category_id"}, &habit); err != nil {
+			return err
+		}
+		if err := audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionUpdate,
+			EntityType: audit.EntityHabit,
+			EntityID:   habit.ID,
+			Before:     before,
+			After:      habit,
+		}); err != nil {
+			return err
+		}
+		if habitIDs[habit.UserID] == nil {
+			userIDs = append(userIDs, habit.UserID)
+		}
+		habitIDs[habit.UserID] = append(habitIDs[habit.UserID], habit.ID)
+	}
+	for _, userID := range userIDs {
+		if err := habitsChanged(tx, habitIDs[userID], time.Now()); err != nil {
+			return err
+		}
+	}
+
+	var children []models.HabitCategory
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("parent_id = ?", category.ID).
+		Order("id").Find(&children).Error; err != nil {
+		return err
+	}
+	for _, before := range children {
+		child := before
+		child.ParentID = category.ParentID
+		child.Version = before.Version + 1
+		if err := versionedUpdate(tx, &before, before.Version, []string{"parent_id"}, &child); err != nil {
+			return err
+		}
+		if err := audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionUpdate,
+			EntityType: audit.EntityCategory,
+			EntityID:   child.ID,
+			Before:     before,
+			After:      child,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := versionedDelete(tx, category, category.Version); err != nil {
+		return err
+	}
+	// Categories have no owner, and sync sends tombstones without a user
+	// to every user
+	tombstone := newTombstone(nil, audit.EntityCategory, category.ID, category.ClientID)
+	return tx.Create(&tombstone).Error
+}
+
+// buildCategoryTree nests categories under their parents and returns the
+// roots. The order of categories is kept within each level.
+func buildCategoryTree(categories []models.HabitCategory) []models.HabitCategory {
+	byParent := make(map[uint][]models.HabitCategory)
+	for _, category := range categories {
+		var parentID uint
+		if category.ParentID != nil {
+			parentID = *category.ParentID
+		}
+		byParent[parentID] = append(byParent[parentID], category)
+	}
+	return categoryChildren(byParent, 0)
+}
+
+func categoryChildren(byParent map[uint][]models.HabitCategory, parentID uint) []models.HabitCategory {
+	children := byParent[parentID]
+	if children == nil {
+		return []models.HabitCategory{}
+	}
+	for i := range children {
+		children[i].Children = categoryChildren(byParent, children[i].ID)
+	}
+	return children
+}
+
+// categorySubtree returns the ID of a category and of all its descendants
+func categorySubtree(db *gorm.DB, id uint) ([]uint, error) {
+	var categories []models.HabitCategory
+	if err := db.Select("id", "parent_id").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	children := make(map[uint][]uint)
+	for _, category := range categories {
+		if category.ParentID != nil {
+			children[*category.ParentID] = append(children[*category.ParentID], category.ID)
+		}
+	}
+
+	// A cycle can only come from bad data, but must not loop forever
+	ids := []uint{id}
+	seen := map[uint]bool{id: true}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range children[ids[i]] {
+			if !seen[child] {
+				seen[child] = true
+				ids = append(ids, child)
+			}
+		}
+	}
+	return ids, nil
+}
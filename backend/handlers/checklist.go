@@ -0,0 +1,419 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"habit-tracker/apperror"
+	"habit-tracker/audit"
+	"habit-tracker/database"
+	"habit-tracker/models"
+)
+
+// maxHabitItems limits the length of a habit's checklist
+const maxHabitItems = 50
+
+var errHabitItemNotFound = apperror.NotFound(apperror.CodeNotFound, "Checklist item not found")
+
+// HabitItemRequest creates or renames a checklist item. Position is only
+// used on create; without it the item is appended.
+type HabitItemRequest struct {
+	Name     string `json:"name" binding:"required,max=100"`
+	Position *int   `json:"position" binding:"omitempty,min=0"`
+}
+
+// HabitItemOrderRequest lists every item of a checklist in its new order
+type HabitItemOrderRequest struct {
+	ItemIDs []uint `json:"item_ids" binding:"required"`
+}
+
+// ItemStats is how often a checklist item was ticked over a period. The rate
+// is over the days the habit was due.
+type ItemStats struct {
+	ItemID      uint    `json:"item_id"`
+	Name        string  `json:"name"`
+	CheckedDays int     `json:"checked_days"`
+	Rate        float64 `json:"rate"`
+}
+
+func GetHabitItems(c *gin.Context) {
+	habit, err := loadOwnedHabit(c)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	items, err := habitItems(database.DB, habit.ID)
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch checklist items"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Checklist items retrieved successfully",
+		"data":    items,
+	})
+}
+
+func CreateHabitItem(c *gin.Context) {
+	habit, err := loadOwnedHabit(c)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var req HabitItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(c, apperror.Validation(err))
+		return
+	}
+	if err := validateItemName(req.Name); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	item := models.HabitItem{HabitID: habit.ID, UserID: habit.UserID, Name: strings.TrimSpace(req.Name)}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the habit so concurrent requests keep positions consistent
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Habit{}, habit.ID).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.HabitItem{}).Where("habit_id = ?", habit.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= maxHabitItems {
+			return apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed").
+				WithField("name", fmt.Sprintf("a checklist has at most %d items", maxHabitItems))
+		}
+
+		item.Position = int(count)
+		if req.Position != nil && *req.Position < item.Position {
+			item.Position = *req.Position
+			if err := tx.Model(&models.HabitItem{}).
+				Where("habit_id = ? AND position >= ?", habit.ID, item.Position).
+				Update("position", gorm.Expr("position + 1")).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Create(&item).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionCreate,
+			EntityType: audit.EntityHabitItem,
+			EntityID:   item.ID,
+			After:      item,
+		})
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to create checklist item"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"error":   false,
+		"message": "Checklist item created successfully",
+		"data":    item,
+	})
+}
+
+// UpdateHabitItem renames a checklist item. Use the order endpoint to move it.
+func UpdateHabitItem(c *gin.Context) {
+	habit, item, err := loadHabitItem(c)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var req HabitItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(c, apperror.Validation(err))
+		return
+	}
+	if err := validateItemName(req.Name); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	before := item
+	item.Name = strings.TrimSpace(req.Name)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&item).Where("habit_id = ?", habit.ID).Update("name", item.Name).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionUpdate,
+			EntityType: audit.EntityHabitItem,
+			EntityID:   item.ID,
+			Before:     before,
+			After:      item,
+		})
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to update checklist item"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Checklist item updated successfully",
+		"data":    item,
+	})
+}
+
+// DeleteHabitItem removes a checklist item and closes the gap in the order.
+// Logs that ticked it keep its ID, which is ignored from then on.
+func DeleteHabitItem(c *gin.Context) {
+	habit, item, err := loadHabitItem(c)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Habit{}, habit.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&item).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.HabitItem{}).
+			Where("habit_id = ? AND position > ?", habit.ID, item.Position).
+			Update("position", gorm.Expr("position - 1")).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionDelete,
+			EntityType: audit.EntityHabitItem,
+			EntityID:   item.ID,
+			Before:     item,
+		})
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to delete checklist item"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Checklist item deleted successfully",
+	})
+}
+
+// ReorderHabitItems sets the order of a checklist. The request must list
+// every item exactly once.
+func ReorderHabitItems(c *gin.Context) {
+	habit, err := loadOwnedHabit(c)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var req HabitItemOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(c, apperror.Validation(err))
+		return
+	}
+
+	var items []models.HabitItem
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Habit{}, habit.ID).Error; err != nil {
+			return err
+		}
+
+		current, err := habitItems(tx, habit.ID)
+		if err != nil {
+			return err
+		}
+		byID := make(map[uint]models.HabitItem, len(current))
+		for _, item := range current {
+			byID[item.ID] = item
+		}
+		if len(req.ItemIDs) != len(current) {
+			return apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed").
+				WithField("item_ids", "must list every checklist item exactly once")
+		}
+
+		for position, id := range req.ItemIDs {
+			item, ok := byID[id]
+			if !ok {
+				return apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed").
+					WithField("item_ids", "must list every checklist item exactly once")
+			}
+			delete(byID, id)
+
+			if item.Position != position {
+				before := item
+				item.Position = position
+				if err := tx.Model(&item).Update("position", position).Error; err != nil {
+					return err
+				}
+				if err := audit.Record(tx, c, audit.Entry{
+					Action:     audit.ActionUpdate,
+					EntityType: audit.EntityHabitItem,
+					EntityID:   item.ID,
+					Before:     before,
+					After:      item,
+				}); err != nil {
+					return err
+				}
+			}
+			items = append(items, item)
+		}
+		return nil
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to reorder checklist items"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Checklist items reordered successfully",
+		"data":    items,
+	})
+}
+
+// loadOwnedHabit loads the current user's habit named by the :id path
+// parameter
+func loadOwnedHabit(c *gin.Context) (models.Habit, error) {
+	userID, _ := c.Get("userID")
+	var habit models.Habit
+	habitID, err := parseIDParam(c, "id", "Invalid habit ID")
+	if err != nil {
+		return habit, err
+	}
+	if err := database.DB.Where("id = ? AND user_id = ?", habitID, userID).First(&habit).Error; err != nil {
+		return habit, apperror.FromDB(err, errHabitNotFound, "Failed to fetch habit")
+	}
+	return habit, nil
+}
+
+// loadHabitItem loads the habit and checklist item named by the :id and
+// :itemId path parameters
+func loadHabitItem(c *gin.Context) (models.Habit, models.HabitItem, error) {
+	var item models.HabitItem
+	habit, err := loadOwnedHabit(c)
+	if err != nil {
+		return habit, item, err
+	}
+	itemID, err := parseIDParam(c, "itemId", "Invalid checklist item ID")
+	if err != nil {
+		return habit, item, err
+	}
+	if err := database.DB.Where("id = ? AND habit_id = ?", itemID, habit.ID).First(&item).Error; err != nil {
+		return habit, item, apperror.FromDB(err, errHabitItemNotFound, "Failed to fetch checklist item")
+	}
+	return habit, item, nil
+}
+
+func habitItems(db *gorm.DB, habitID uint) ([]models.HabitItem, error) {
+	items := []models.HabitItem{}
+	err := db.Where("habit_id = ?", habitID).Order("position").Find(&items).Error
+	return items, err
+}
+
+func validateItemName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed").WithField("name", "is required")
+	}
+	return nil
+}
+
+// applyChecklist keeps only the ticked IDs that are items of the habit's
+// checklist, dropping deleted items. When derive is set, completed follows
+// the habit's checklist threshold. Logs without checked items are left
+// alone.
+func applyChecklist(habit *models.Habit, habitLog *models.HabitLog, derive bool) error {
+	if habitLog.CheckedItems == nil {
+		return nil
+	}
+
+	items, err := habitItems(database.DB, habit.ID)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed").
+			WithField("checked_items", "the habit has no checklist")
+	}
+
+	exists := make(map[uint]bool, len(items))
+	for _, item := range items {
+		exists[item.ID] = true
+	}
+	checked := models.IDList{}
+	for _, id := range habitLog.CheckedItems {
+		if exists[id] {
+			checked = append(checked, id)
+			delete(exists, id)
+		}
+	}
+	habitLog.CheckedItems = checked
+
+	if derive && habit.Kind != models.HabitKindQuit {
+		habitLog.Completed = len(checked)*100 >= habit.ChecklistThreshold*len(items)
+	}
+	return nil
+}
+
+// applyLogChecklist derives completed from the checklist when a patch only
+// changed the ticked items
+func applyLogChecklist(habit *models.Habit, habitLog *models.HabitLog, columns []string) ([]string, error) {
+	if !containsColumn(columns, "checked_items") {
+		return columns, nil
+	}
+	derive := !containsColumn(columns, "completed") && !containsColumn(columns, "status")
+	if err := applyChecklist(habit, habitLog, derive); err != nil {
+		return nil, err
+	}
+	if derive && habitLog.CheckedItems != nil {
+		columns = append(columns, "completed")
+	}
+	return columns, nil
+}
+
+// checklistStats counts per item the days from from to to on which it was
+// ticked
+func checklistStats(habit *models.Habit, from, to time.Time, dueDays int) ([]ItemStats, error) {
+	items, err := habitItems(database.DB, habit.ID)
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+
+	var habitLogs []models.HabitLog
+	if err := database.DB.Select("date", "checked_items").
+		Where("habit_id = ? AND checked_items IS NOT NULL AND date >= ? AND date < ?", habit.ID, from, to.AddDate(0, 0, 1)).
+		Find(&habitLogs).Error; err != nil {
+		return nil, err
+	}
+	days := make(map[uint]map[string]bool, len(items))
+	for _, habitLog := range habitLogs {
+		key := startOfDay(habitLog.Date).Format("2006-01-02")
+		for _, id := range habitLog.CheckedItems {
+			if days[id] == nil {
+				days[id] = make(map[string]bool)
+			}
+			days[id][key] = true
+		}
+	}
+
+	result := make([]ItemStats, 0, len(items))
+	for _, item := range items {
+		itemStats := ItemStats{ItemID: item.ID, Name: item.Name, CheckedDays: len(days[item.ID])}
+		if dueDays > 0 {
+			itemStats.Rate = min(float64(itemStats.CheckedDays)/float64(dueDays), 1)
+		}
+		result = append(result, itemStats)
+	}
+	return result, nil
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"habit-tracker/apperror"
+)
+
+var errVersionMismatch = apperror.New(http.StatusPreconditionFailed, apperror.CodePreconditionFailed, "Resource has been modified since it was last fetched")
+
+// versionETag is the strong ETag of a single versioned resource
+func versionETag(version uint) string {
+	return fmt.Sprintf(`"v%d"`, version)
+}
+
+// contentETag is a weak ETag derived from a response payload, used for lists
+func contentETag(payload interface{}) (string, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// checkIfMatch enforces an If-Match header against the loaded version.
+// Requests without the header are allowed; the versioned write still
+// guarantees nobody else changed the row in between.
+func checkIfMatch(c *gin.Context, version uint) error {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		return nil
+	}
+
+	current := versionETag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		// If-Match requires strong comparison, so weak tags never match
+		if tag == "*" || tag == current {
+			return nil
+		}
+	}
+	return errVersionMismatch
+}
+
+// notModified sets the ETag header and, when If-None-Match matches, replies
+// 304 and reports true so the handler can stop.
+func notModified(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+
+	header := c.GetHeader("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	// If-None-Match uses weak comparison
+	current := strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == current {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// respondList writes a list response with a content ETag, or 304 when the
+// client already has the same page
+func respondList(c *gin.Context, message string, data interface{}, page Pagination) {
+	body := gin.H{
+		"error":      false,
+		"message":    message,
+		"data":       data,
+		"pagination": page,
+	}
+
+	etag, err := contentETag(body)
+	if err == nil && notModified(c, etag) {
+		return
+	}
+
+	c.JSON(http.StatusOK, body)
+}
+
+// versionedUpdate writes the selected columns of values only if the row still
+// has expectedVersion. The caller sets the incremented version on values.
+func versionedUpdate(tx *gorm.DB, model interface{}, expectedVersion uint, columns []string, values interface{}) error {
+	selected := append(append([]string{}, columns...), "version")
+	result := tx.Model(model).Where("version = ?", expectedVersion).Select(selected).Updates(values)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errVersionMismatch
+	}
+	return nil
+}
+
+// versionedDelete deletes the row only if it still has expectedVersion
+func versionedDelete(tx *gorm.DB, model interface{}, expectedVersion uint) error {
+	result := tx.Where("version = ?", expectedVersion).Delete(model)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errVersionMismatch
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"habit-tracker/apperror"
+	"habit-tracker/database"
+	"habit-tracker/models"
+)
+
+var eventSortColumns = sortableColumns{
+	"id":         kindInt,
+	"created_at": kindTime,
+}
+
+// GetEvents lists the user's events, newest first. Clients polling for new
+// events pass the last ID they have seen as after_id.
+func GetEvents(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	params, err := parseListParams(c, eventSortColumns, "-id")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	query := database.DB.Where("user_id = ?", userID)
+	if eventType := c.Query("type"); eventType != "" {
+		query = query.Where("type = ?", eventType)
+	}
+	afterID, err := parseIDQuery(c, "after_id", "Invalid event ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if afterID != nil {
+		query = query.Where("id > ?", *afterID)
+	}
+
+	var events []models.Event
+	if err := params.apply(query).Find(&events).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch events"))
+		return
+	}
+
+	events, page, err := paginate(params, events)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal("Failed to build pagination cursor", err))
+		return
+	}
+
+	respondList(c, "Events retrieved successfully", events, page)
+}
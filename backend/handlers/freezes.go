@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"habit-tracker/apperror"
+	"habit-tracker/audit"
+	"habit-tracker/database"
+	"habit-tracker/models"
+)
+
+// defaultFreezesPerMonth is used when STREAK_FREEZES_PER_MONTH is unset or invalid
+const defaultFreezesPerMonth = 2
+
+var errStreakFreezeNotFound = apperror.NotFound(apperror.CodeNotFound, "Streak freeze not found")
+
+// StreakFreezeRequest freezes a habit's streak on Date
+type StreakFreezeRequest struct {
+	Date string `json:"date" binding:"required,datetime=2006-01-02"`
+}
+
+// StreakFreezeAllowance reports the freezes of a month
+type StreakFreezeAllowance struct {
+	Month     string                `json:"month"`
+	Allowance int                   `json:"allowance"`
+	Used      int                   `json:"used"`
+	Remaining int                   `json:"remaining"`
+	Freezes   []models.StreakFreeze `json:"freezes"`
+}
+
+// freezesPerMonth is how many streak freezes a user may use per calendar
+// month, read from STREAK_FREEZES_PER_MONTH
+func freezesPerMonth() int {
+	allowance, err := strconv.Atoi(os.Getenv("STREAK_FREEZES_PER_MONTH"))
+	if err != nil || allowance < 0 {
+		return defaultFreezesPerMonth
+	}
+	return allowance
+}
+
+func monthRange(t time.Time) (time.Time, time.Time) {
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local)
+	return start, start.AddDate(0, 1, 0)
+}
+
+// GetStreakFreezes reports the freezes used in ?month=YYYY-MM, by default
+// the current month
+func GetStreakFreezes(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	month := time.Now()
+	if raw := c.Query("month"); raw != "" {
+		parsed, err := time.ParseInLocation("2006-01", raw, time.Local)
+		if err != nil {
+			apperror.Abort(c, apperror.InvalidParam("month", "month must be in YYYY-MM format"))
+			return
+		}
+		month = parsed
+	}
+	start, end := monthRange(month)
+
+	allowance := StreakFreezeAllowance{Month: start.Format("2006-01"), Allowance: freezesPerMonth()}
+	if err := database.DB.Where("user_id = ? AND date >= ? AND date < ?", userID, start, end).
+		Order("date").Find(&allowance.Freezes).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch streak freezes"))
+		return
+	}
+	allowance.Used = len(allowance.Freezes)
+	allowance.Remaining = max(allowance.Allowance-allowance.Used, 0)
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Streak freezes retrieved successfully",
+		"data":    allowance,
+	})
+}
+
+func CreateStreakFreeze(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	habitID, err := parseIDParam(c, "id", "Invalid habit ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var req StreakFreezeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(c, apperror.Validation(err))
+		return
+	}
+
+	var habit models.Habit
+	if err := database.DB.Where("id = ? AND user_id = ?", habitID, userID).First(&habit).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errHabitNotFound, "Failed to fetch habit"))
+		return
+	}
+
+	// The date passed the binding's format check
+	date, _ := time.ParseInLocation("2006-01-02", req.Date, time.Local)
+	if err := validateLogDate("date", &habit, date, time.Now()); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	freeze := models.StreakFreeze{UserID: habit.UserID, HabitID: habit.ID, Date: date}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the user so concurrent requests cannot exceed the allowance
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&user, habit.UserID).Error; err != nil {
+			return err
+		}
+
+		var completed int64
+		if err := tx.Model(&models.HabitLog{}).
+			Where("habit_id = ? AND status = ? AND date >= ? AND date < ?", habit.ID, models.LogStatusCompleted, date, date.AddDate(0, 0, 1)).
+			Count(&completed).Error; err != nil {
+			return err
+		}
+		if completed > 0 {
+			return apperror.Conflict(apperror.CodeAlreadyCompleted, "The habit was already completed on that day")
+		}
+
+		start, end := monthRange(date)
+		var used int64
+		if err := tx.Model(&models.StreakFreeze{}).
+			Where("user_id = ? AND date >= ? AND date < ?", habit.UserID, start, end).
+			Count(&used).Error; err != nil {
+			return err
+		}
+		if allowance := freezesPerMonth(); int(used) >= allowance {
+			return apperror.Conflict(apperror.CodeFreezeLimit, fmt.Sprintf("All %d streak freezes of %s are used", allowance, start.Format("2006-01")))
+		}
+
+		if err := tx.Create(&freeze).Error; err != nil {
+			return err
+		}
+		if err := habitsChanged(tx, []uint{habit.ID}, time.Now()); err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionCreate,
+			EntityType: audit.EntityStreakFreeze,
+			EntityID:   freeze.ID,
+			After:      freeze,
+		})
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to freeze streak"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"error":   false,
+		"message": "Streak frozen successfully",
+		"data":    freeze,
+	})
+}
+
+// DeleteStreakFreeze removes a freeze, returning it to the month's allowance
+func DeleteStreakFreeze(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	habitID, err := parseIDParam(c, "id", "Invalid habit ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	freezeID, err := parseIDParam(c, "freezeId", "Invalid streak freeze ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var freeze models.StreakFreeze
+	if err := database.DB.Where("id = ? AND habit_id = ? AND user_id = ?", freezeID, habitID, userID).First(&freeze).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errStreakFreezeNotFound, "Failed to fetch streak freeze"))
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&freeze).Error; err != nil {
+			return err
+		}
+		if err := habitsChanged(tx, []uint{freeze.HabitID}, time.Now()); err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionDelete,
+			EntityType: audit.EntityStreakFreeze,
+			EntityID:   freeze.ID,
+			Before:     freeze,
+		})
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to delete streak freeze"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Streak freeze deleted successfully",
+	})
+}
@@ -0,0 +1,595 @@
+package handlers
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"habit-tracker/apperror"
+	"habit-tracker/audit"
+	"habit-tracker/database"
+	"habit-tracker/events"
+	"habit-tracker/models"
+	"habit-tracker/stats"
+)
+
+// goalsJob names the background job that checks goals as days pass
+const goalsJob = "goals"
+
+var errGoalNotFound = apperror.NotFound(apperror.CodeGoalNotFound, "Goal not found")
+
+var goalSortColumns = sortableColumns{
+	"id":         kindInt,
+	"target":     kindInt,
+	"progress":   kindInt,
+	"created_at": kindTime,
+}
+
+// GoalRequest sets a goal on a habit. StartDate defaults to today and
+// Deadline is optional.
+type GoalRequest struct {
+	Name      string `json:"name" binding:"max=255"`
+	Type      string `json:"type" binding:"required,oneof=count streak amount"`
+	Target    int    `json:"target" binding:"required,min=1"`
+	StartDate string `json:"start_date" binding:"omitempty,datetime=2006-01-02"`
+	Deadline  string `json:"deadline" binding:"omitempty,datetime=2006-01-02"`
+}
+
+// GoalProgress is a goal with how far along it is. ProjectedDate is when the
+// goal is reached at the pace so far: the day it was achieved, or nil while
+// there is no pace yet or the goal is unreachable. OnTrack reports whether
+// that is by the deadline.
+type GoalProgress struct {
+	models.Goal
+	Percent       float64 `json:"percent"`
+	ProjectedDate *string `json:"projected_date"`
+	OnTrack       bool    `json:"on_track"`
+}
+
+// goalEvent is the data of the events a goal emits
+type goalEvent struct {
+	GoalID   uint   `json:"goal_id"`
+	HabitID  uint   `json:"habit_id"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Target   int    `json:"target"`
+	Progress int    `json:"progress"`
+	Percent  int    `json:"percent,omitempty"`
+}
+
+// goalState is a goal measured against its habit's logs
+type goalState struct {
+	// progress counts toward the target: days completed, the longest
+	// streak or the amount logged
+	progress int
+	// streak is the current streak, which a streak goal still has to grow
+	streak    int
+	todayDone bool
+	// daysLeft is the number of days on which progress can still be made
+	// before the deadline, -1 without a deadline
+	daysLeft int
+}
+
+func GetGoals(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	params, err := parseListParams(c, goalSortColumns, "id")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	query := database.DB.Where("user_id = ?", userID)
+	habitID, err := parseIDQuery(c, "habit_id", "Invalid habit ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if habitID != nil {
+		query = query.Where("habit_id = ?", *habitID)
+	}
+	if status := c.Query("status"); status != "" {
+		if status != models.GoalStatusActive && status != models.GoalStatusAchieved && status != models.GoalStatusUnreachable {
+			apperror.Abort(c, apperror.InvalidParam("status", "status must be active, achieved or unreachable"))
+			return
+		}
+		query = query.Where("status = ?", status)
+	}
+
+	var goals []models.Goal
+	if err := params.apply(query).Find(&goals).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch goals"))
+		return
+	}
+
+	goals, page, err := paginate(params, goals)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal("Failed to build pagination cursor", err))
+		return
+	}
+
+	result, err := goalsProgress(goals, time.Now())
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to calculate goal progress"))
+		return
+	}
+	respondList(c, "Goals retrieved successfully", result, page)
+}
+
+func GetGoal(c *gin.Context) {
+	goal, err := loadGoal(c)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	result, err := goalsProgress([]models.Goal{goal}, time.Now())
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to calculate goal progress"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Goal retrieved successfully",
+		"data":    result[0],
+	})
+}
+
+// CreateGoal sets a goal on a habit. Logs already in the goal's period
+// count toward it right away.
+func CreateGoal(c *gin.Context) {
+	habit, err := loadOwnedHabit(c)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var req GoalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(c, apperror.Validation(err))
+		return
+	}
+
+	now := time.Now()
+	goal := models.Goal{
+		UserID:    habit.UserID,
+		HabitID:   habit.ID,
+		Name:      req.Name,
+		Type:      req.Type,
+		Target:    req.Target,
+		StartDate: startOfDay(now),
+		Status:    models.GoalStatusActive,
+	}
+	// Both dates passed the binding's format check
+	if req.StartDate != "" {
+		goal.StartDate, _ = time.ParseInLocation("2006-01-02", req.StartDate, time.Local)
+	}
+	if req.Deadline != "" {
+		deadline, _ := time.ParseInLocation("2006-01-02", req.Deadline, time.Local)
+		goal.Deadline = &deadline
+	}
+	if err := validateGoal(&habit, &goal); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(&goal).Error; err != nil {
+			return err
+		}
+		if err := evaluateGoal(tx, &goal, &habit, now); err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionCreate,
+			EntityType: audit.EntityGoal,
+			EntityID:   goal.ID,
+			After:      goal,
+		})
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to create goal"))
+		return
+	}
+
+	result, err := goalsProgress([]models.Goal{goal}, now)
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to calculate goal progress"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"error":   false,
+		"message": "Goal created successfully",
+		"data":    result[0],
+	})
+}
+
+func DeleteGoal(c *gin.Context) {
+	goal, err := loadGoal(c)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("goal_id = ?", goal.ID).Delete(&models.GoalMilestone{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&goal).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionDelete,
+			EntityType: audit.EntityGoal,
+			EntityID:   goal.ID,
+			Before:     goal,
+		})
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to delete goal"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Goal deleted successfully",
+	})
+}
+
+// EvaluateGoals re-checks the active goals with a deadline, which can
+// become unreachable just by days passing. It runs as a background job; a
+// habit whose goals fail is logged and retried on the next run.
+func EvaluateGoals(now time.Time) error {
+	var habitIDs []uint
+	if err := database.DB.Model(&models.Goal{}).Distinct("habit_id").
+		Where("status = ? AND deadline IS NOT NULL", models.GoalStatusActive).
+		Pluck("habit_id", &habitIDs).Error; err != nil {
+		return err
+	}
+
+	for _, habitID := range habitIDs {
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			var habit models.Habit
+			if err := tx.First(&habit, habitID).Error; err != nil {
+				return err
+			}
+			return evaluateHabitGoals(tx, []models.Habit{habit}, now)
+		})
+		if err != nil {
+			log.Printf("Job %s failed for habit %d: %v", goalsJob, habitID, err)
+		}
+	}
+	return nil
+}
+
+// loadGoal loads the goal named by the :id path parameter, scoped to the
+// current user
+func loadGoal(c *gin.Context) (models.Goal, error) {
+	userID, _ := c.Get("userID")
+	var goal models.Goal
+	id, err := parseIDParam(c, "id", "Invalid goal ID")
+	if err != nil {
+		return goal, err
+	}
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&goal).Error; err != nil {
+		return goal, apperror.FromDB(err, errGoalNotFound, "Failed to fetch goal")
+	}
+	return goal, nil
+}
+
+func validateGoal(habit *models.Habit, goal *models.Goal) error {
+	if goal.Type == models.GoalTypeAmount && habit.Kind == models.HabitKindQuit {
+		return apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed").
+			WithField("type", "amount goals are not available for quit habits")
+	}
+	if goal.StartDate.Before(startOfDay(habit.StartDate)) {
+		return apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed").
+			WithField("start_date", "must not be before the habit's start date")
+	}
+	if goal.Deadline != nil && goal.Deadline.Before(goal.StartDate) {
+		return apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed").
+			WithField("deadline", "must not be before start_date")
+	}
+	return nil
+}
+
+// evaluateHabitGoals brings the goals of the given habits up to date after
+// their logs changed. Achieved goals are final and left alone.
+func evaluateHabitGoals(tx *gorm.DB, habits []models.Habit, now time.Time) error {
+	if len(habits) == 0 {
+		return nil
+	}
+	byID := make(map[uint]*models.Habit, len(habits))
+	habitIDs := make([]uint, len(habits))
+	for i := range habits {
+		byID[habits[i].ID] = &habits[i]
+		habitIDs[i] = habits[i].ID
+	}
+
+	// Locked so concurrent writes emit each event only once
+	var goals []models.Goal
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("habit_id IN ? AND status <> ?", habitIDs, models.GoalStatusAchieved).
+		Order("id").Find(&goals).Error; err != nil {
+		return err
+	}
+
+	for i := range goals {
+		if err := evaluateGoal(tx, &goals[i], byID[goals[i].HabitID], now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evaluateGoal measures goal, stores its new progress and status and
+// records the milestones it passed. Events are emitted for new milestones
+// and when the goal is achieved or becomes unreachable.
+func evaluateGoal(tx *gorm.DB, goal *models.Goal, habit *models.Habit, now time.Time) error {
+	state, err := measureGoal(tx, goal, habit, startOfDay(now))
+	if err != nil {
+		return err
+	}
+
+	before := *goal
+	goal.Progress = state.progress
+	switch {
+	case state.progress >= goal.Target:
+		goal.Status = models.GoalStatusAchieved
+		goal.AchievedAt = &now
+		goal.UnreachableAt = nil
+	case !goalReachable(goal, state):
+		goal.Status = models.GoalStatusUnreachable
+		if before.Status != models.GoalStatusUnreachable {
+			goal.UnreachableAt = &now
+		}
+	default:
+		goal.Status = models.GoalStatusActive
+		goal.UnreachableAt = nil
+	}
+
+	if err := recordMilestones(tx, goal, now); err != nil {
+		return err
+	}
+
+	if goal.Progress == before.Progress && goal.Status == before.Status {
+		return nil
+	}
+	if err := tx.Model(goal).Select("progress", "status", "achieved_at", "unreachable_at").Updates(goal).Error; err != nil {
+		return err
+	}
+	if goal.Status == before.Status {
+		return nil
+	}
+
+	switch goal.Status {
+	case models.GoalStatusAchieved:
+		return emitGoalEvent(tx, events.GoalAchieved, goal, 0)
+	case models.GoalStatusUnreachable:
+		return emitGoalEvent(tx, events.GoalUnreachable, goal, 0)
+	}
+	return nil
+}
+
+// recordMilestones records every milestone the goal has passed and not
+// recorded yet. Reaching 100% is reported as the goal being achieved.
+func recordMilestones(tx *gorm.DB, goal *models.Goal, now time.Time) error {
+	var reached []int
+	if err := tx.Model(&models.GoalMilestone{}).Where("goal_id = ?", goal.ID).Pluck("percent", &reached).Error; err != nil {
+		return err
+	}
+	recorded := make(map[int]bool, len(reached))
+	for _, percent := range reached {
+		recorded[percent] = true
+	}
+
+	for _, percent := range models.GoalMilestones {
+		if recorded[percent] || goal.Progress*100 < percent*goal.Target {
+			continue
+		}
+		milestone := models.GoalMilestone{GoalID: goal.ID, Percent: percent, ReachedAt: now}
+		if err := tx.Create(&milestone).Error; err != nil {
+			return err
+		}
+		goal.Milestones = append(goal.Milestones, milestone)
+		if percent < 100 {
+			if err := emitGoalEvent(tx, events.GoalMilestone, goal, percent); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func emitGoalEvent(tx *gorm.DB, eventType string, goal *models.Goal, percent int) error {
+	return events.Emit(tx, goal.UserID, events.Entry{
+		Type:       eventType,
+		EntityType: audit.EntityGoal,
+		EntityID:   goal.ID,
+		Data: goalEvent{
+			GoalID:   goal.ID,
+			HabitID:  goal.HabitID,
+			Name:     goal.Name,
+			Type:     goal.Type,
+			Target:   goal.Target,
+			Progress: goal.Progress,
+			Percent:  percent,
+		},
+	})
+}
+
+// measureGoal reads the goal's progress from the habit's logs, counting the
+// days from the goal's start to today or its deadline, whichever is first.
+// Reads go through db so a transaction sees its own writes.
+func measureGoal(db *gorm.DB, goal *models.Goal, habit *models.Habit, today time.Time) (goalState, error) {
+	state := goalState{daysLeft: -1}
+	from := startOfDay(goal.StartDate)
+	to := today
+	if goal.Deadline != nil {
+		deadline := startOfDay(*goal.Deadline)
+		if deadline.Before(to) {
+			to = deadline
+		}
+
+		first := today
+		if from.After(first) {
+			first = from
+		}
+		state.daysLeft = daysBetween(first, deadline) + 1
+		if state.daysLeft < 0 {
+			state.daysLeft = 0
+		}
+	}
+	// An archived habit's history ends on the day it was archived
+	if habit.ArchivedAt != nil && startOfDay(*habit.ArchivedAt).Before(to) {
+		to = startOfDay(*habit.ArchivedAt)
+	}
+	if to.Before(from) {
+		return state, nil
+	}
+
+	if goal.Type == models.GoalTypeAmount {
+		// Every logged amount counts, even on a day short of the daily target
+		err := db.Model(&models.HabitLog{}).Select("COALESCE(SUM(amount), 0)").
+			Where("habit_id = ? AND status <> ? AND date >= ? AND date < ?", habit.ID, models.LogStatusSkipped, from, to.AddDate(0, 0, 1)).
+			Scan(&state.progress).Error
+		return state, err
+	}
+
+	days, err := habitCalendar(db, habit, from, to)
+	if err != nil {
+		return state, err
+	}
+	states := make([]stats.State, len(days))
+	for i, day := range days {
+		states[i] = day.State
+	}
+	summary := stats.Summarize(states)
+	state.streak = summary.CurrentStreak
+	state.progress = summary.CompletedDays
+	if goal.Type == models.GoalTypeStreak {
+		state.progress = summary.LongestStreak
+	}
+	if to.Equal(today) && len(days) > 0 && days[len(days)-1].State == stats.Done {
+		state.todayDone = true
+		if state.daysLeft > 0 {
+			state.daysLeft--
+		}
+	}
+	return state, nil
+}
+
+// goalReachable reports whether the goal can still be reached by its
+// deadline, assuming the habit is completed on every day left. An amount
+// goal stays reachable until the deadline has passed.
+func goalReachable(goal *models.Goal, state goalState) bool {
+	switch {
+	case state.daysLeft < 0:
+		return true
+	case state.daysLeft == 0:
+		return false
+	case goal.Type == models.GoalTypeCount:
+		return state.progress+state.daysLeft >= goal.Target
+	case goal.Type == models.GoalTypeStreak:
+		return state.streak+state.daysLeft >= goal.Target
+	}
+	return true
+}
+
+// goalsProgress measures the goals for display, loading their habits and
+// milestones
+func goalsProgress(goals []models.Goal, now time.Time) ([]GoalProgress, error) {
+	result := make([]GoalProgress, 0, len(goals))
+	if len(goals) == 0 {
+		return result, nil
+	}
+
+	goalIDs := make([]uint, len(goals))
+	habitIDs := make([]uint, len(goals))
+	for i, goal := range goals {
+		goalIDs[i] = goal.ID
+		habitIDs[i] = goal.HabitID
+	}
+
+	var habits []models.Habit
+	if err := database.DB.Where("id IN ?", uniqueIDs(habitIDs)).Find(&habits).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]*models.Habit, len(habits))
+	for i := range habits {
+		byID[habits[i].ID] = &habits[i]
+	}
+
+	var milestones []models.GoalMilestone
+	if err := database.DB.Where("goal_id IN ?", goalIDs).Order("percent").Find(&milestones).Error; err != nil {
+		return nil, err
+	}
+	reached := make(map[uint][]models.GoalMilestone)
+	for _, milestone := range milestones {
+		reached[milestone.GoalID] = append(reached[milestone.GoalID], milestone)
+	}
+
+	today := startOfDay(now)
+	for _, goal := range goals {
+		goal.Milestones = reached[goal.ID]
+		item := GoalProgress{Goal: goal}
+
+		state := goalState{progress: goal.Progress, daysLeft: -1}
+		if habit := byID[goal.HabitID]; habit != nil && goal.Status != models.GoalStatusAchieved {
+			var err error
+			if state, err = measureGoal(database.DB, &goal, habit, today); err != nil {
+				return nil, err
+			}
+		}
+
+		item.Percent = math.Min(100, math.Round(float64(state.progress)*1000/float64(goal.Target))/10)
+		if projected := projectGoal(&goal, state, today); projected != nil {
+			date := projected.Format("2006-01-02")
+			item.ProjectedDate = &date
+			item.OnTrack = goal.Deadline == nil || !projected.After(startOfDay(*goal.Deadline))
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// projectGoal estimates when the goal is reached. Count and amount goals
+// continue at their average pace since the goal started; a streak goal
+// assumes the current streak is kept up.
+func projectGoal(goal *models.Goal, state goalState, today time.Time) *time.Time {
+	switch goal.Status {
+	case models.GoalStatusAchieved:
+		return goal.AchievedAt
+	case models.GoalStatusUnreachable:
+		return nil
+	}
+
+	from := startOfDay(goal.StartDate)
+	if today.Before(from) {
+		return nil
+	}
+
+	if goal.Type == models.GoalTypeStreak {
+		remaining := goal.Target - state.streak
+		if !state.todayDone {
+			remaining--
+		}
+		projected := today.AddDate(0, 0, remaining)
+		return &projected
+	}
+
+	if state.progress == 0 {
+		return nil
+	}
+	elapsed := daysBetween(from, today) + 1
+	perDay := float64(state.progress) / float64(elapsed)
+	remaining := int(math.Ceil(float64(goal.Target-state.progress) / perDay))
+	projected := today.AddDate(0, 0, remaining)
+	return &projected
+}
@@ -1,63 +1,144 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
-	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"habit-tracker/apperror"
+	"habit-tracker/audit"
 	"habit-tracker/database"
 	"habit-tracker/models"
 )
 
+var (
+	errHabitNotFound    = apperror.NotFound(apperror.CodeHabitNotFound, "Habit not found")
+	errHabitLogNotFound = apperror.NotFound(apperror.CodeHabitLogNotFound, "Habit log not found")
+)
+
+// habitColumns are the columns a client may write with PUT or PATCH
+var habitColumns = patchColumns(HabitPatch{})
+
+// habitLogColumns are the log columns a client may write with PUT or PATCH
+var habitLogColumns = patchColumns(HabitLogPatch{})
+
+var habitSortColumns = sortableColumns{
+	"id":             kindInt,
+	"name":           kindString,
+	"is_active":      kindBool,
+	"target_per_day": kindInt,
+	"position":       kindInt,
+	"time_of_day":    kindString,
+	"start_date":     kindTime,
+	"created_at":     kindTime,
+	"updated_at":     kindTime,
+}
+
+var habitLogSortColumns = sortableColumns{
+	"id":         kindInt,
+	"date":       kindTime,
+	"completed":  kindBool,
+	"created_at": kindTime,
+}
+
 func GetHabits(c *gin.Context) {
 	userID, _ := c.Get("userID")
-	
+
+	params, err := parseListParams(c, habitSortColumns, "position")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	query := database.DB.Where("user_id = ?", userID)
+
+	// Filters
+	isActive, err := parseBoolQuery(c, "is_active")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if isActive != nil {
+		query = query.Where("is_active = ?", *isActive)
+	}
+	archived, err := parseBoolQuery(c, "archived")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if archived != nil && *archived {
+		query = query.Where("archived_at IS NOT NULL")
+	} else {
+		query = query.Where("archived_at IS NULL")
+	}
+	categoryID, err := parseIDQuery(c, "category_id", "Invalid category ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if categoryID != nil {
+		query = query.Where("category_id = ?", *categoryID)
+	}
+	tags, err := parseTagFilter(c)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	query = tags.apply(query)
+	if search := strings.TrimSpace(c.Query("q")); search != "" {
+		query = query.Where("name LIKE ?", "%"+escapeLike(search)+"%")
+	}
+
 	var habits []models.Habit
-	if err := database.DB.Where("user_id = ?", userID).Preload("Category").Find(&habits).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   true,
-			"message": "Failed to fetch habits",
-		})
+	if err := params.apply(query).Preload("Category").Preload("Tags").Find(&habits).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch habits"))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"error":   false,
-		"message": "Habits retrieved successfully",
-		"data":    habits,
-	})
+	habits, page, err := paginate(params, habits)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal("Failed to build pagination cursor", err))
+		return
+	}
+
+	respondList(c, "Habits retrieved successfully", habits, page)
 }
 
 func CreateHabit(c *gin.Context) {
 	userID, _ := c.Get("userID")
-	
+
 	var habit models.Habit
 	if err := c.ShouldBindJSON(&habit); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   true,
-			"message": "Invalid request data",
-			"details": err.Error(),
-		})
+		apperror.Abort(c, apperror.Validation(err))
 		return
 	}
 
+	habit.ID, habit.Version, habit.ClientID = 0, 1, nil
 	habit.UserID = userID.(uint)
-	if habit.StartDate.IsZero() {
-		habit.StartDate = time.Now()
+	habit.ArchivedAt, habit.Outcome, habit.ArchiveStats = nil, "", nil
+	applyHabitDefaults(&habit)
+	if err := validateHabit(&habit); err != nil {
+		apperror.Abort(c, err)
+		return
 	}
 
-	if err := database.DB.Create(&habit).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   true,
-			"message": "Failed to create habit",
-		})
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return insertHabit(tx, c, &habit)
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to create habit"))
 		return
 	}
 
-	// Load the category relationship
-	database.DB.Preload("Category").First(&habit, habit.ID)
+	// Load the category and tag relationships
+	database.DB.Preload("Category").Preload("Tags").First(&habit, habit.ID)
 
+	c.Header("ETag", versionETag(habit.Version))
 	c.JSON(http.StatusCreated, gin.H{
 		"error":   false,
 		"message": "Habit created successfully",
@@ -67,21 +148,19 @@ func CreateHabit(c *gin.Context) {
 
 func GetHabit(c *gin.Context) {
 	userID, _ := c.Get("userID")
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	id, err := parseIDParam(c, "id", "Invalid habit ID")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   true,
-			"message": "Invalid habit ID",
-		})
+		apperror.Abort(c, err)
 		return
 	}
 
 	var habit models.Habit
-	if err := database.DB.Where("id = ? AND user_id = ?", uint(id), userID).Preload("Category").First(&habit).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":   true,
-			"message": "Habit not found",
-		})
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).Preload("Category").Preload("Tags").First(&habit).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errHabitNotFound, "Failed to fetch habit"))
+		return
+	}
+
+	if notModified(c, versionETag(habit.Version)) {
 		return
 	}
 
@@ -94,44 +173,71 @@ func GetHabit(c *gin.Context) {
 
 func UpdateHabit(c *gin.Context) {
 	userID, _ := c.Get("userID")
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	id, err := parseIDParam(c, "id", "Invalid habit ID")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   true,
-			"message": "Invalid habit ID",
-		})
+		apperror.Abort(c, err)
 		return
 	}
 
 	var habit models.Habit
-	if err := database.DB.Where("id = ? AND user_id = ?", uint(id), userID).First(&habit).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":   true,
-			"message": "Habit not found",
-		})
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&habit).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errHabitNotFound, "Failed to fetch habit"))
 		return
 	}
 
+	if err := checkIfMatch(c, habit.Version); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	before := habit
 	if err := c.ShouldBindJSON(&habit); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   true,
-			"message": "Invalid request data",
-			"details": err.Error(),
-		})
+		apperror.Abort(c, apperror.Validation(err))
 		return
 	}
 
-	if err := database.DB.Save(&habit).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   true,
-			"message": "Failed to update habit",
+	// Ownership and bookkeeping fields are never taken from the client
+	habit.ID = before.ID
+	habit.UserID = before.UserID
+	habit.CreatedAt = before.CreatedAt
+	habit.Version = before.Version + 1
+	if err := validateHabit(&habit); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := versionedUpdate(tx, &before, before.Version, habitColumns, &habit); err != nil {
+			return err
+		}
+		// Checked after the update locks the habit, so no log can be
+		// written in between
+		if err := checkKindChange(tx, &before, &habit); err != nil {
+			return err
+		}
+		if err := recordPauseChange(tx, &before, &habit, time.Now()); err != nil {
+			return err
+		}
+		if err := habitsChanged(tx, []uint{habit.ID}, time.Now()); err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionUpdate,
+			EntityType: audit.EntityHabit,
+			EntityID:   habit.ID,
+			Before:     before,
+			After:      habit,
 		})
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to update habit"))
 		return
 	}
 
-	// Load the category relationship
-	database.DB.Preload("Category").First(&habit, habit.ID)
+	// Load the category and tag relationships
+	database.DB.Preload("Category").Preload("Tags").First(&habit, habit.ID)
 
+	c.Header("ETag", versionETag(habit.Version))
 	c.JSON(http.StatusOK, gin.H{
 		"error":   false,
 		"message": "Habit updated successfully",
@@ -139,14 +245,81 @@ func UpdateHabit(c *gin.Context) {
 	})
 }
 
-func DeleteHabit(c *gin.Context) {
+func PatchHabit(c *gin.Context) {
 	userID, _ := c.Get("userID")
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	id, err := parseIDParam(c, "id", "Invalid habit ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var habit models.Habit
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&habit).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errHabitNotFound, "Failed to fetch habit"))
+		return
+	}
+
+	if err := checkIfMatch(c, habit.Version); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	before := habit
+	columns, err := bindMergePatch(c, &habit, HabitPatch{})
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   true,
-			"message": "Invalid habit ID",
+		apperror.Abort(c, err)
+		return
+	}
+	if err := validateHabit(&habit); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	if len(columns) > 0 {
+		habit.Version = before.Version + 1
+		err = database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := versionedUpdate(tx, &before, before.Version, columns, &habit); err != nil {
+				return err
+			}
+			if err := checkKindChange(tx, &before, &habit); err != nil {
+				return err
+			}
+			if err := recordPauseChange(tx, &before, &habit, time.Now()); err != nil {
+				return err
+			}
+			if err := habitsChanged(tx, []uint{habit.ID}, time.Now()); err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionUpdate,
+				EntityType: audit.EntityHabit,
+				EntityID:   habit.ID,
+				Before:     before,
+				After:      habit,
+			})
 		})
+		if err != nil {
+			apperror.Abort(c, apperror.FromDB(err, nil, "Failed to update habit"))
+			return
+		}
+	}
+
+	// Reload to return the stored values and the category relationship
+	database.DB.Preload("Category").Preload("Tags").First(&habit, id)
+
+	c.Header("ETag", versionETag(habit.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Habit updated successfully",
+		"data":    habit,
+	})
+}
+
+func DeleteHabit(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := parseIDParam(c, "id", "Invalid habit ID")
+	if err != nil {
+		apperror.Abort(c, err)
 		return
 	}
 
@@ -160,46 +333,43 @@ func DeleteHabit(c *gin.Context) {
 
 	// Verify habit exists and belongs to user
 	var habit models.Habit
-	if err := tx.Where("id = ? AND user_id = ?", uint(id), userID).First(&habit).Error; err != nil {
+	if err := tx.Where("id = ? AND user_id = ?", id, userID).First(&habit).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":   true,
-			"message": "Habit not found",
-		})
+		apperror.Abort(c, apperror.FromDB(err, errHabitNotFound, "Failed to fetch habit"))
 		return
 	}
 
-	// Delete all habit logs first (foreign key constraint)
-	if err := tx.Where("habit_id = ?", uint(id)).Delete(&models.HabitLog{}).Error; err != nil {
+	if err := checkIfMatch(c, habit.Version); err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   true,
-			"message": "Failed to delete habit logs",
-			"details": err.Error(),
-		})
+		apperror.Abort(c, err)
 		return
 	}
 
-	// Delete the habit
-	if err := tx.Where("id = ? AND user_id = ?", uint(id), userID).Delete(&models.Habit{}).Error; err != nil {
+	// Delete the habit and its logs, unless it changed since it was loaded
+	files, err := deleteHabitWithLogs(tx, &habit)
+	if err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   true,
-			"message": "Failed to delete habit",
-			"details": err.Error(),
-		})
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to delete habit"))
+		return
+	}
+
+	if err := audit.Record(tx, c, audit.Entry{
+		Action:     audit.ActionDelete,
+		EntityType: audit.EntityHabit,
+		EntityID:   habit.ID,
+		Before:     habit,
+	}); err != nil {
+		tx.Rollback()
+		apperror.Abort(c, apperror.Internal("Failed to record audit log", err))
 		return
 	}
 
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   true,
-			"message": "Failed to commit transaction",
-			"details": err.Error(),
-		})
+		apperror.Abort(c, apperror.Internal("Failed to commit transaction", err))
 		return
 	}
+	removeStoredFiles(files)
 
 	c.JSON(http.StatusOK, gin.H{
 		"error":   false,
@@ -207,35 +377,115 @@ func DeleteHabit(c *gin.Context) {
 	})
 }
 
+// deleteHabitWithLogs removes a habit, its logs, their attachments, its
+// checklist, streak freezes, pauses, timer sessions and goals and leaves
+// tombstones so sync clients learn about the deletion. It returns the
+// storage keys of the attachment files to remove once the transaction has
+// committed.
+func deleteHabitWithLogs(tx *gorm.DB, habit *models.Habit) ([]string, error) {
+	var habitLogs []models.HabitLog
+	if err := tx.Select("id", "client_id").Where("habit_id = ?", habit.ID).Find(&habitLogs).Error; err != nil {
+		return nil, err
+	}
+
+	logIDs := make([]uint, 0, len(habitLogs))
+	for _, habitLog := range habitLogs {
+		logIDs = append(logIDs, habitLog.ID)
+	}
+	files, err := deleteLogAttachments(tx, logIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Delete all habit logs first (foreign key constraint)
+	if err := tx.Where("habit_id = ?", habit.ID).Delete(&models.HabitLog{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("habit_id = ?", habit.ID).Delete(&models.StreakFreeze{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("habit_id = ?", habit.ID).Delete(&models.HabitPause{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("habit_id = ?", habit.ID).Delete(&models.TimerSession{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("habit_id = ?", habit.ID).Delete(&models.HabitItem{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("habit_id = ?", habit.ID).Delete(&models.HabitTag{}).Error; err != nil {
+		return nil, err
+	}
+	goals := tx.Model(&models.Goal{}).Select("id").Where("habit_id = ?", habit.ID)
+	if err := tx.Where("goal_id IN (?)", goals).Delete(&models.GoalMilestone{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("habit_id = ?", habit.ID).Delete(&models.Goal{}).Error; err != nil {
+		return nil, err
+	}
+	if err := versionedDelete(tx, habit, habit.Version); err != nil {
+		return nil, err
+	}
+	if err := reconcileHabitXP(tx, habit.UserID, habit.ID, nil, nil, "", time.Now()); err != nil {
+		return nil, err
+	}
+
+	tombstones := []models.Tombstone{newTombstone(&habit.UserID, audit.EntityHabit, habit.ID, habit.ClientID)}
+	for _, habitLog := range habitLogs {
+		tombstones = append(tombstones, newTombstone(&habit.UserID, audit.EntityHabitLog, habitLog.ID, habitLog.ClientID))
+	}
+	if err := tx.Create(&tombstones).Error; err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
 func ToggleHabit(c *gin.Context) {
 	userID, _ := c.Get("userID")
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	id, err := parseIDParam(c, "id", "Invalid habit ID")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   true,
-			"message": "Invalid habit ID",
-		})
+		apperror.Abort(c, err)
 		return
 	}
 
 	var habit models.Habit
-	if err := database.DB.Where("id = ? AND user_id = ?", uint(id), userID).First(&habit).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":   true,
-			"message": "Habit not found",
-		})
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&habit).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errHabitNotFound, "Failed to fetch habit"))
 		return
 	}
 
+	if err := checkIfMatch(c, habit.Version); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	before := habit
 	habit.IsActive = !habit.IsActive
-	if err := database.DB.Save(&habit).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   true,
-			"message": "Failed to toggle habit",
+	habit.Version = before.Version + 1
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := versionedUpdate(tx, &before, before.Version, []string{"is_active"}, &habit); err != nil {
+			return err
+		}
+		if err := recordPauseChange(tx, &before, &habit, time.Now()); err != nil {
+			return err
+		}
+		if err := habitsChanged(tx, []uint{habit.ID}, time.Now()); err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionUpdate,
+			EntityType: audit.EntityHabit,
+			EntityID:   habit.ID,
+			Before:     before,
+			After:      habit,
 		})
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to toggle habit"))
 		return
 	}
 
+	c.Header("ETag", versionETag(habit.Version))
 	c.JSON(http.StatusOK, gin.H{
 		"error":   false,
 		"message": "Habit toggled successfully",
@@ -245,49 +495,62 @@ func ToggleHabit(c *gin.Context) {
 
 func CreateHabitLog(c *gin.Context) {
 	userID, _ := c.Get("userID")
-	habitID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	habitID, err := parseIDParam(c, "id", "Invalid habit ID")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   true,
-			"message": "Invalid habit ID",
-		})
+		apperror.Abort(c, err)
 		return
 	}
 
 	// Verify habit belongs to user
 	var habit models.Habit
-	if err := database.DB.Where("id = ? AND user_id = ?", uint(habitID), userID).First(&habit).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":   true,
-			"message": "Habit not found",
-		})
+	if err := database.DB.Where("id = ? AND user_id = ?", habitID, userID).First(&habit).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errHabitNotFound, "Failed to fetch habit"))
 		return
 	}
 
 	var habitLog models.HabitLog
 	if err := c.ShouldBindJSON(&habitLog); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   true,
-			"message": "Invalid request data",
-			"details": err.Error(),
-		})
+		apperror.Abort(c, apperror.Validation(err))
 		return
 	}
 
-	habitLog.HabitID = uint(habitID)
+	habitLog.ID, habitLog.Version, habitLog.ClientID = 0, 1, nil
+	habitLog.HabitID = habitID
 	habitLog.UserID = userID.(uint)
 	if habitLog.Date.IsZero() {
 		habitLog.Date = time.Now()
 	}
+	if err := applyChecklist(&habit, &habitLog, habitLog.Status == ""); err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch checklist items"))
+		return
+	}
+	applyLogStatus(&habitLog, habitLog.Status != "")
+	if err := applyHabitKind(&habit, &habitLog); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if err := validateHabitLog(&habitLog); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	now := time.Now()
+	if err := validateLogDate("date", &habit, habitLog.Date, now); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
 
-	if err := database.DB.Create(&habitLog).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   true,
-			"message": "Failed to create habit log",
-		})
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := insertHabitLog(tx, c, &habitLog); err != nil {
+			return err
+		}
+		return logsChanged(tx, []uint{habit.ID}, now)
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to create habit log"))
 		return
 	}
 
+	c.Header("ETag", versionETag(habitLog.Version))
 	c.JSON(http.StatusCreated, gin.H{
 		"error":   false,
 		"message": "Habit log created successfully",
@@ -297,37 +560,233 @@ func CreateHabitLog(c *gin.Context) {
 
 func GetHabitLogs(c *gin.Context) {
 	userID, _ := c.Get("userID")
-	habitID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	habitID, err := parseIDParam(c, "id", "Invalid habit ID")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   true,
-			"message": "Invalid habit ID",
-		})
+		apperror.Abort(c, err)
 		return
 	}
 
 	// Verify habit belongs to user
 	var habit models.Habit
-	if err := database.DB.Where("id = ? AND user_id = ?", uint(habitID), userID).First(&habit).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":   true,
-			"message": "Habit not found",
-		})
+	if err := database.DB.Where("id = ? AND user_id = ?", habitID, userID).First(&habit).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errHabitNotFound, "Failed to fetch habit"))
+		return
+	}
+
+	params, err := parseListParams(c, habitLogSortColumns, "-date")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	query := database.DB.Where("habit_id = ? AND user_id = ?", habitID, userID)
+
+	// Filters
+	from, err := parseDateQuery(c, "from")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if from != nil {
+		query = query.Where("date >= ?", *from)
+	}
+	to, err := parseDateQuery(c, "to")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if to != nil {
+		// to is inclusive, so compare against the start of the next day
+		query = query.Where("date < ?", to.AddDate(0, 0, 1))
+	}
+	completed, err := parseBoolQuery(c, "completed")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if completed != nil {
+		query = query.Where("completed = ?", *completed)
+	}
+	query, err = filterJournal(c, query)
+	if err != nil {
+		apperror.Abort(c, err)
 		return
 	}
 
 	var habitLogs []models.HabitLog
-	if err := database.DB.Where("habit_id = ? AND user_id = ?", uint(habitID), userID).Order("date DESC").Find(&habitLogs).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   true,
-			"message": "Failed to fetch habit logs",
-		})
+	if err := params.apply(query).Find(&habitLogs).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch habit logs"))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"error":   false,
-		"message": "Habit logs retrieved successfully",
-		"data":    habitLogs,
+	habitLogs, page, err := paginate(params, habitLogs)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal("Failed to build pagination cursor", err))
+		return
+	}
+
+	respondList(c, "Habit logs retrieved successfully", habitLogs, page)
+}
+
+// applyHabitDefaults fills fields a client may omit when creating a habit
+func applyHabitDefaults(habit *models.Habit) {
+	if habit.StartDate.IsZero() {
+		habit.StartDate = time.Now()
+	}
+	if habit.TargetPerDay == 0 {
+		habit.TargetPerDay = 1
+	}
+	if habit.Kind == "" {
+		habit.Kind = models.HabitKindBuild
+	}
+	if habit.ChecklistThreshold == 0 {
+		habit.ChecklistThreshold = 100
+	}
+	if habit.TimeOfDay == "" {
+		habit.TimeOfDay = models.TimeOfDayAnytime
+	}
+	if habit.Difficulty == "" {
+		habit.Difficulty = models.DifficultyMedium
+	}
+}
+
+// insertHabit creates a habit at the end of the user's order
+func insertHabit(tx *gorm.DB, c *gin.Context, habit *models.Habit) error {
+	var last *int
+	if err := tx.Model(&models.Habit{}).Select("MAX(position)").Where("user_id = ?", habit.UserID).Scan(&last).Error; err != nil {
+		return err
+	}
+	habit.Position = 0
+	if last != nil {
+		habit.Position = *last + 1
+	}
+
+	if err := tx.Omit(clause.Associations).Create(habit).Error; err != nil {
+		return err
+	}
+	return audit.Record(tx, c, audit.Entry{
+		Action:     audit.ActionCreate,
+		EntityType: audit.EntityHabit,
+		EntityID:   habit.ID,
+		After:      habit,
 	})
 }
+
+func validTimeOfDay(timeOfDay string) bool {
+	for _, value := range models.TimesOfDay {
+		if timeOfDay == value {
+			return true
+		}
+	}
+	return false
+}
+
+// maxUnitLength is the size of the habits.unit column
+const maxUnitLength = 32
+
+// validateHabit checks the rules the database cannot express
+func validateHabit(habit *models.Habit) error {
+	appErr := apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed")
+	if strings.TrimSpace(habit.Name) == "" {
+		appErr = appErr.WithField("name", "is required")
+	}
+	if habit.TargetPerDay < 1 {
+		appErr = appErr.WithField("target_per_day", "must be at least 1")
+	}
+	if habit.Kind != models.HabitKindBuild && habit.Kind != models.HabitKindQuit {
+		appErr = appErr.WithField("kind", "must be one of: build quit")
+	}
+	if habit.ChecklistThreshold < 1 || habit.ChecklistThreshold > 100 {
+		appErr = appErr.WithField("checklist_threshold", "must be between 1 and 100")
+	}
+	if !validTimeOfDay(habit.TimeOfDay) {
+		appErr = appErr.WithField("time_of_day", "must be one of: "+strings.Join(models.TimesOfDay, " "))
+	}
+	switch habit.Difficulty {
+	case models.DifficultyEasy, models.DifficultyMedium, models.DifficultyHard:
+	default:
+		appErr = appErr.WithField("difficulty", "must be one of: easy medium hard")
+	}
+	if utf8.RuneCountInString(habit.Unit) > maxUnitLength {
+		appErr = appErr.WithField("unit", fmt.Sprintf("must be at most %d characters", maxUnitLength))
+	}
+	if habit.StartDate.IsZero() {
+		appErr = appErr.WithField("start_date", "is required")
+	}
+	if len(appErr.Fields) > 0 {
+		return appErr
+	}
+	return nil
+}
+
+// Limits of the journaling fields of a habit log
+const (
+	minLogRating        = 1
+	maxLogRating        = 5
+	maxNoteLength       = 10000
+	maxSkipReasonLength = 255
+	maxLogTags          = 20
+	maxTagLength        = 32
+)
+
+// validateHabitLog checks the rules the database cannot express. Tags are
+// trimmed and deduplicated first.
+func validateHabitLog(habitLog *models.HabitLog) error {
+	habitLog.Tags = normalizeTags(habitLog.Tags)
+
+	appErr := apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed")
+	if habitLog.Date.IsZero() {
+		appErr = appErr.WithField("date", "is required")
+	}
+	if habitLog.Amount < 0 {
+		appErr = appErr.WithField("amount", "must be at least 0")
+	}
+	switch habitLog.Status {
+	case models.LogStatusCompleted, models.LogStatusSkipped, models.LogStatusFailed:
+	default:
+		appErr = appErr.WithField("status", "must be one of: completed skipped failed")
+	}
+	if habitLog.Mood != nil && (*habitLog.Mood < minLogRating || *habitLog.Mood > maxLogRating) {
+		appErr = appErr.WithField("mood", fmt.Sprintf("must be between %d and %d", minLogRating, maxLogRating))
+	}
+	if habitLog.Effort != nil && (*habitLog.Effort < minLogRating || *habitLog.Effort > maxLogRating) {
+		appErr = appErr.WithField("effort", fmt.Sprintf("must be between %d and %d", minLogRating, maxLogRating))
+	}
+	if utf8.RuneCountInString(habitLog.SkipReason) > maxSkipReasonLength {
+		appErr = appErr.WithField("skip_reason", fmt.Sprintf("must be at most %d characters", maxSkipReasonLength))
+	}
+	if utf8.RuneCountInString(habitLog.Note) > maxNoteLength {
+		appErr = appErr.WithField("note", fmt.Sprintf("must be at most %d characters", maxNoteLength))
+	}
+	if len(habitLog.Tags) > maxLogTags {
+		appErr = appErr.WithField("tags", fmt.Sprintf("must have at most %d tags", maxLogTags))
+	}
+	for _, tag := range habitLog.Tags {
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			appErr = appErr.WithField("tags", fmt.Sprintf("each tag must be at most %d characters", maxTagLength))
+			break
+		}
+	}
+	if len(appErr.Fields) > 0 {
+		return appErr
+	}
+	return nil
+}
+
+// normalizeTags trims tags and drops empty and duplicate ones
+func normalizeTags(tags models.StringList) models.StringList {
+	if tags == nil {
+		return nil
+	}
+	normalized := make(models.StringList, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"habit-tracker/apperror"
+	"habit-tracker/database"
+	"habit-tracker/models"
+)
+
+var journalSortColumns = sortableColumns{
+	"id":         kindInt,
+	"date":       kindTime,
+	"created_at": kindTime,
+}
+
+// GetJournal lists the notes of all the user's habits in chronological order
+func GetJournal(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	params, err := parseListParams(c, journalSortColumns, "date")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	query := database.DB.Where("user_id = ? AND note <> ''", userID)
+
+	// Filters
+	from, err := parseDateQuery(c, "from")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if from != nil {
+		query = query.Where("date >= ?", *from)
+	}
+	to, err := parseDateQuery(c, "to")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if to != nil {
+		// to is inclusive, so compare against the start of the next day
+		query = query.Where("date < ?", to.AddDate(0, 0, 1))
+	}
+	habitID, err := parseIDQuery(c, "habit_id", "Invalid habit ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if habitID != nil {
+		query = query.Where("habit_id = ?", *habitID)
+	}
+	query, err = filterJournal(c, query)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var habitLogs []models.HabitLog
+	if err := params.apply(query).Preload("Habit").Find(&habitLogs).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch journal"))
+		return
+	}
+
+	habitLogs, page, err := paginate(params, habitLogs)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal("Failed to build pagination cursor", err))
+		return
+	}
+
+	respondList(c, "Journal retrieved successfully", habitLogs, page)
+}
+
+// filterJournal applies the journaling filters shared by the log list and the
+// journal: mood and effort ranges, a tag, and full-text search over notes
+func filterJournal(c *gin.Context, query *gorm.DB) (*gorm.DB, error) {
+	ranges := []struct {
+		param  string
+		clause string
+	}{
+		{"mood_min", "mood >= ?"},
+		{"mood_max", "mood <= ?"},
+		{"effort_min", "effort >= ?"},
+		{"effort_max", "effort <= ?"},
+	}
+	for _, r := range ranges {
+		value, err := parseIntQuery(c, r.param, minLogRating, maxLogRating)
+		if err != nil {
+			return nil, err
+		}
+		if value != nil {
+			query = query.Where(r.clause, *value)
+		}
+	}
+
+	if tag := strings.TrimSpace(c.Query("tag")); tag != "" {
+		query = query.Where("JSON_CONTAINS(tags, JSON_QUOTE(?))", tag)
+	}
+	if search := strings.TrimSpace(c.Query("q")); search != "" {
+		query = query.Where("MATCH(note) AGAINST (? IN NATURAL LANGUAGE MODE)", search)
+	}
+	return query, nil
+}
@@ -0,0 +1,755 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"habit-tracker/apperror"
+	"habit-tracker/audit"
+	"habit-tracker/database"
+	"habit-tracker/models"
+)
+
+const (
+	BatchCreated = "created"
+	BatchFailed  = "failed"
+)
+
+// BatchLogRequest logs several habits at once, e.g. from a daily check-in
+type BatchLogRequest struct {
+	Logs []BatchLogItem `json:"logs" binding:"required,min=1,max=100,dive"`
+}
+
+// BatchLogItem is one log entry. A status decides completed; otherwise, when
+// completed is omitted it is derived from amount (amount reaches the habit's
+// target_per_day), and defaults to true when both are omitted.
+type BatchLogItem struct {
+	HabitID      uint       `json:"habit_id" binding:"required"`
+	Date         *time.Time `json:"date"`
+	Completed    *bool      `json:"completed"`
+	Status       *string    `json:"status" binding:"omitempty,oneof=completed skipped failed"`
+	SkipReason   string     `json:"skip_reason" binding:"max=255"`
+	Amount       *int       `json:"amount" binding:"omitempty,min=0"`
+	Note         string     `json:"note"`
+	Mood         *int       `json:"mood"`
+	Effort       *int       `json:"effort"`
+	Tags         []string   `json:"tags"`
+	CheckedItems []uint     `json:"checked_items"`
+}
+
+// BatchLogResult reports the outcome of the item at Index
+type BatchLogResult struct {
+	Index   int               `json:"index"`
+	HabitID uint              `json:"habit_id"`
+	Status  string            `json:"status"`
+	Code    string            `json:"code,omitempty"`
+	Message string            `json:"message,omitempty"`
+	Fields  map[string]string `json:"fields,omitempty"`
+	Log     *models.HabitLog  `json:"log,omitempty"`
+}
+
+// BatchLogResponse holds one result per submitted item, in request order
+type BatchLogResponse struct {
+	Created int              `json:"created"`
+	Failed  int              `json:"failed"`
+	Results []BatchLogResult `json:"results"`
+}
+
+// CreateHabitLogsBatch creates many habit logs in one transaction. Items
+// that fail are reported in their result and do not affect the others.
+func CreateHabitLogsBatch(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req BatchLogRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(c, apperror.Validation(err))
+		return
+	}
+
+	// Verify ownership of every referenced habit with a single query
+	habitIDs := make([]uint, 0, len(req.Logs))
+	for _, item := range req.Logs {
+		habitIDs = append(habitIDs, item.HabitID)
+	}
+	var habits []models.Habit
+	if err := database.DB.Where("id IN ? AND user_id = ?", habitIDs, userID).Find(&habits).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch habits"))
+		return
+	}
+	owned := make(map[uint]models.Habit, len(habits))
+	for _, habit := range habits {
+		owned[habit.ID] = habit
+	}
+
+	now := time.Now()
+	resp := BatchLogResponse{Results: make([]BatchLogResult, len(req.Logs))}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var changed []uint
+		for i, item := range req.Logs {
+			result := BatchLogResult{Index: i, HabitID: item.HabitID}
+
+			habit, ok := owned[item.HabitID]
+			if !ok {
+				resp.Results[i] = batchFailure(result, errHabitNotFound)
+				continue
+			}
+
+			habitLog, err := newBatchLog(item, habit, now)
+			if err != nil {
+				resp.Results[i] = batchFailure(result, err)
+				continue
+			}
+			if err := applyHabitKind(&habit, &habitLog); err != nil {
+				resp.Results[i] = batchFailure(result, err)
+				continue
+			}
+			if err := validateHabitLog(&habitLog); err != nil {
+				resp.Results[i] = batchFailure(result, err)
+				continue
+			}
+			if err := validateLogDate("date", &habit, habitLog.Date, now); err != nil {
+				resp.Results[i] = batchFailure(result, err)
+				continue
+			}
+
+			// A savepoint keeps a failed insert from aborting the whole batch
+			savepoint := fmt.Sprintf("batch_log_%d", i)
+			if err := tx.SavePoint(savepoint).Error; err != nil {
+				return err
+			}
+			if err := insertHabitLog(tx, c, &habitLog); err != nil {
+				if rollbackErr := tx.RollbackTo(savepoint).Error; rollbackErr != nil {
+					return rollbackErr
+				}
+				resp.Results[i] = batchFailure(result, apperror.FromDB(err, nil, "Failed to create habit log"))
+				continue
+			}
+
+			result.Status = BatchCreated
+			result.Log = &habitLog
+			resp.Results[i] = result
+			changed = append(changed, habit.ID)
+		}
+		return logsChanged(tx, changed, now)
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to create habit logs"))
+		return
+	}
+
+	for _, result := range resp.Results {
+		if result.Status == BatchCreated {
+			resp.Created++
+		} else {
+			resp.Failed++
+		}
+	}
+
+	status := http.StatusCreated
+	if resp.Created == 0 {
+		status = http.StatusOK
+	}
+	c.JSON(status, gin.H{
+		"error":   false,
+		"message": fmt.Sprintf("%d habit logs created, %d failed", resp.Created, resp.Failed),
+		"data":    resp,
+	})
+}
+
+func newBatchLog(item BatchLogItem, habit models.Habit, now time.Time) (models.HabitLog, error) {
+	habitLog := models.HabitLog{
+		HabitID:   habit.ID,
+		UserID:    habit.UserID,
+		Date:      now,
+		Completed: habit.Kind != models.HabitKindQuit,
+		Note:      item.Note,
+		Mood:      item.Mood,
+		Effort:    item.Effort,
+		Tags:      item.Tags,
+	}
+	if item.CheckedItems != nil {
+		habitLog.CheckedItems = models.IDList(item.CheckedItems)
+	}
+	if item.Date != nil {
+		habitLog.Date = *item.Date
+	}
+	if item.Amount != nil {
+		habitLog.Amount = *item.Amount
+		habitLog.Completed = completedByAmount(&habit, habitLog.Amount)
+	}
+	if item.Completed != nil {
+		habitLog.Completed = *item.Completed
+	}
+	if item.Status != nil {
+		habitLog.Status = *item.Status
+		habitLog.SkipReason = item.SkipReason
+	}
+	derive := item.Amount == nil && item.Completed == nil && item.Status == nil
+	if err := applyChecklist(&habit, &habitLog, derive); err != nil {
+		return habitLog, err
+	}
+	applyLogStatus(&habitLog, item.Status != nil)
+	return habitLog, nil
+}
+
+// insertHabitLog creates a log, at most one per habit and day, and records
+// it in the audit log
+func insertHabitLog(tx *gorm.DB, c *gin.Context, habitLog *models.HabitLog) error {
+	if err := checkLogDayFree(tx, nil, habitLog); err != nil {
+		return err
+	}
+	if err := tx.Omit(clause.Associations).Create(habitLog).Error; err != nil {
+		return err
+	}
+	return audit.Record(tx, c, audit.Entry{
+		Action:     audit.ActionCreate,
+		EntityType: audit.EntityHabitLog,
+		EntityID:   habitLog.ID,
+		After:      habitLog,
+	})
+}
+
+// logsChanged brings what is derived from the logs of the given habits up
+// to date, in the transaction that wrote them. The habits belong to one
+// user.
+func logsChanged(tx *gorm.DB, habitIDs []uint, now time.Time) error {
+	return refreshHabits(tx, habitIDs, "", now)
+}
+
+// habitsChanged is logsChanged for changes to what the logs count for
+// rather than to the logs themselves: habit edits, pauses, vacations and
+// streak freezes. The XP they correct is recorded as habit_changed.
+func habitsChanged(tx *gorm.DB, habitIDs []uint, now time.Time) error {
+	return refreshHabits(tx, habitIDs, models.XPReasonHabitChanged, now)
+}
+
+// userHabitsChanged is habitsChanged for every habit of a user, for changes
+// such as vacations that apply to all of them
+func userHabitsChanged(tx *gorm.DB, userID uint, now time.Time) error {
+	var habitIDs []uint
+	if err := tx.Model(&models.Habit{}).Where("user_id = ?", userID).Pluck("id", &habitIDs).Error; err != nil {
+		return err
+	}
+	return habitsChanged(tx, habitIDs, now)
+}
+
+// refreshHabits re-evaluates the goals, achievements and XP of the given
+// habits. xpReason overrides the reasons of the XP corrections when set.
+func refreshHabits(tx *gorm.DB, habitIDs []uint, xpReason string, now time.Time) error {
+	if len(habitIDs) == 0 {
+		return nil
+	}
+	var habits []models.Habit
+	if err := tx.Where("id IN ?", uniqueIDs(habitIDs)).Order("id").Find(&habits).Error; err != nil {
+		return err
+	}
+	if len(habits) == 0 {
+		return nil
+	}
+	// Take the user lock the XP needs before the goals are locked, in the
+	// same order as writes that lock the user first
+	if err := lockUser(tx, habits[0].UserID); err != nil {
+		return err
+	}
+	if err := evaluateHabitGoals(tx, habits, now); err != nil {
+		return err
+	}
+	calendars := newCalendarCache(tx, now)
+	if err := awardAchievements(tx, habits, calendars, now); err != nil {
+		return err
+	}
+	for i := range habits {
+		if err := reconcileHabitXP(tx, habits[i].UserID, habits[i].ID, &habits[i], calendars, xpReason, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func batchFailure(result BatchLogResult, err error) BatchLogResult {
+	appErr := apperror.FromDB(err, nil, "Failed to create habit log")
+	result.Status = BatchFailed
+	result.Code = appErr.Code
+	result.Message = appErr.Message
+	result.Fields = appErr.Fields
+	return result
+}
+
+// maxRangeDays limits how many days one range request may cover
+const maxRangeDays = 366
+
+// LogRangeRequest marks every day from From to To (inclusive) with Status
+type LogRangeRequest struct {
+	From   string `json:"from" binding:"required,datetime=2006-01-02"`
+	To     string `json:"to" binding:"required,datetime=2006-01-02"`
+	Status string `json:"status" binding:"required,oneof=completed skipped failed"`
+	// Reason is stored on the logs when Status is skipped
+	Reason string `json:"reason" binding:"max=255"`
+	// Amount and CheckedItems, when given, are set on every log of the range
+	Amount       *int          `json:"amount"`
+	CheckedItems models.IDList `json:"checked_items"`
+}
+
+// LogRangeResponse lists the logs of the range after the change
+type LogRangeResponse struct {
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Logs    []models.HabitLog `json:"logs"`
+}
+
+// CreateHabitLogRange sets the status of every day in a range, creating a
+// log for days without one and updating the logs of the others
+func CreateHabitLogRange(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	habitID, err := parseIDParam(c, "id", "Invalid habit ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var req LogRangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(c, apperror.Validation(err))
+		return
+	}
+
+	var habit models.Habit
+	if err := database.DB.Where("id = ? AND user_id = ?", habitID, userID).First(&habit).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errHabitNotFound, "Failed to fetch habit"))
+		return
+	}
+
+	// Both dates passed the binding's format check
+	from, _ := time.ParseInLocation("2006-01-02", req.From, time.Local)
+	to, _ := time.ParseInLocation("2006-01-02", req.To, time.Local)
+	if to.Before(from) {
+		apperror.Abort(c, apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed").WithField("to", "must not be before from"))
+		return
+	}
+	// Counted in calendar days, which are not all 24 hours long
+	if to.After(from.AddDate(0, 0, maxRangeDays-1)) {
+		apperror.Abort(c, apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed").WithField("to", fmt.Sprintf("range must be at most %d days", maxRangeDays)))
+		return
+	}
+
+	now := time.Now()
+	if err := validateLogDate("from", &habit, from, now); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if err := validateLogDate("to", &habit, to, now); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	// Every day of the range gets the fields of this log, checked the same
+	// way as a single log
+	fields := models.HabitLog{
+		HabitID:      habit.ID,
+		UserID:       habit.UserID,
+		Date:         from,
+		Status:       req.Status,
+		SkipReason:   req.Reason,
+		CheckedItems: req.CheckedItems,
+	}
+	if req.Amount != nil {
+		fields.Amount = *req.Amount
+	}
+	if err := applyChecklist(&habit, &fields, false); err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch checklist items"))
+		return
+	}
+	applyLogStatus(&fields, true)
+	if err := applyHabitKind(&habit, &fields); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if err := validateHabitLog(&fields); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	columns := []string{"completed", "skip_reason", "status"}
+	if req.Amount != nil {
+		columns = append(columns, "amount")
+	}
+	if req.CheckedItems != nil {
+		columns = append(columns, "checked_items")
+	}
+
+	resp := LogRangeResponse{}
+	end := to.AddDate(0, 0, 1)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var existing []models.HabitLog
+		if err := tx.Where("habit_id = ? AND date >= ? AND date < ?", habit.ID, from, end).Find(&existing).Error; err != nil {
+			return err
+		}
+		logged := make(map[string]bool, len(existing))
+		for _, habitLog := range existing {
+			logged[startOfDay(habitLog.Date).Format("2006-01-02")] = true
+
+			before := habitLog
+			habitLog.Status = fields.Status
+			habitLog.Completed = fields.Completed
+			habitLog.SkipReason = fields.SkipReason
+			if req.Amount != nil {
+				habitLog.Amount = fields.Amount
+			}
+			if req.CheckedItems != nil {
+				habitLog.CheckedItems = fields.CheckedItems
+			}
+			if reflect.DeepEqual(habitLog, before) {
+				continue
+			}
+			habitLog.Version = before.Version + 1
+			if err := versionedUpdate(tx, &before, before.Version, columns, &habitLog); err != nil {
+				return err
+			}
+			if err := audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionUpdate,
+				EntityType: audit.EntityHabitLog,
+				EntityID:   habitLog.ID,
+				Before:     before,
+				After:      habitLog,
+			}); err != nil {
+				return err
+			}
+			resp.Updated++
+		}
+
+		for day := from; day.Before(end); day = day.AddDate(0, 0, 1) {
+			if logged[day.Format("2006-01-02")] {
+				continue
+			}
+			habitLog := fields
+			habitLog.Date = day
+			if err := insertHabitLog(tx, c, &habitLog); err != nil {
+				return err
+			}
+			resp.Created++
+		}
+		if err := logsChanged(tx, []uint{habit.ID}, now); err != nil {
+			return err
+		}
+
+		return tx.Where("habit_id = ? AND date >= ? AND date < ?", habit.ID, from, end).Order("date").Find(&resp.Logs).Error
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to log date range"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": fmt.Sprintf("%d habit logs created, %d updated", resp.Created, resp.Updated),
+		"data":    resp,
+	})
+}
+
+// loadHabitLog loads the habit and log named by the :id and :logId path
+// parameters, scoped to the current user
+func loadHabitLog(c *gin.Context) (models.Habit, models.HabitLog, error) {
+	userID, _ := c.Get("userID")
+	var habit models.Habit
+	var habitLog models.HabitLog
+
+	habitID, err := parseIDParam(c, "id", "Invalid habit ID")
+	if err != nil {
+		return habit, habitLog, err
+	}
+	logID, err := parseIDParam(c, "logId", "Invalid habit log ID")
+	if err != nil {
+		return habit, habitLog, err
+	}
+
+	if err := database.DB.Where("id = ? AND user_id = ?", habitID, userID).First(&habit).Error; err != nil {
+		return habit, habitLog, apperror.FromDB(err, errHabitNotFound, "Failed to fetch habit")
+	}
+	if err := database.DB.Where("id = ? AND habit_id = ? AND user_id = ?", logID, habitID, userID).First(&habitLog).Error; err != nil {
+		return habit, habitLog, apperror.FromDB(err, errHabitLogNotFound, "Failed to fetch habit log")
+	}
+	return habit, habitLog, nil
+}
+
+func GetHabitLog(c *gin.Context) {
+	_, habitLog, err := loadHabitLog(c)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	if notModified(c, versionETag(habitLog.Version)) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Habit log retrieved successfully",
+		"data":    habitLog,
+	})
+}
+
+func UpdateHabitLog(c *gin.Context) {
+	habit, habitLog, err := loadHabitLog(c)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	if err := checkIfMatch(c, habitLog.Version); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	now := time.Now()
+	if err := checkBackfillWindow("date", habitLog.Date, now); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	before := habitLog
+	// Cleared so an omitted status follows completed
+	habitLog.Status = ""
+	if err := c.ShouldBindJSON(&habitLog); err != nil {
+		apperror.Abort(c, apperror.Validation(err))
+		return
+	}
+
+	// Ownership and bookkeeping fields are never taken from the client
+	habitLog.ID = before.ID
+	habitLog.HabitID = before.HabitID
+	habitLog.UserID = before.UserID
+	habitLog.ClientID = before.ClientID
+	habitLog.CreatedAt = before.CreatedAt
+	habitLog.Version = before.Version + 1
+	if err := applyChecklist(&habit, &habitLog, habitLog.Status == ""); err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch checklist items"))
+		return
+	}
+	applyLogStatus(&habitLog, habitLog.Status != "")
+
+	if err := validateLogChange(&habit, &before, &habitLog, now); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkLogDayFree(tx, &before, &habitLog); err != nil {
+			return err
+		}
+		if err := versionedUpdate(tx, &before, before.Version, habitLogColumns, &habitLog); err != nil {
+			return err
+		}
+		if err := audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionUpdate,
+			EntityType: audit.EntityHabitLog,
+			EntityID:   habitLog.ID,
+			Before:     before,
+			After:      habitLog,
+		}); err != nil {
+			return err
+		}
+		return logsChanged(tx, []uint{habit.ID}, now)
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to update habit log"))
+		return
+	}
+
+	c.Header("ETag", versionETag(habitLog.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Habit log updated successfully",
+		"data":    habitLog,
+	})
+}
+
+func PatchHabitLog(c *gin.Context) {
+	habit, habitLog, err := loadHabitLog(c)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	if err := checkIfMatch(c, habitLog.Version); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	now := time.Now()
+	if err := checkBackfillWindow("date", habitLog.Date, now); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	before := habitLog
+	columns, err := bindMergePatch(c, &habitLog, HabitLogPatch{})
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	columns = applyLogAmount(&habit, &habitLog, columns)
+	if columns, err = applyLogChecklist(&habit, &habitLog, columns); err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch checklist items"))
+		return
+	}
+	columns, statusSet := logStatusColumns(columns)
+	applyLogStatus(&habitLog, statusSet)
+	if err := validateLogChange(&habit, &before, &habitLog, now); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	if len(columns) > 0 {
+		habitLog.Version = before.Version + 1
+		err = database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := checkLogDayFree(tx, &before, &habitLog); err != nil {
+				return err
+			}
+			if err := versionedUpdate(tx, &before, before.Version, columns, &habitLog); err != nil {
+				return err
+			}
+			if err := audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionUpdate,
+				EntityType: audit.EntityHabitLog,
+				EntityID:   habitLog.ID,
+				Before:     before,
+				After:      habitLog,
+			}); err != nil {
+				return err
+			}
+			return logsChanged(tx, []uint{habit.ID}, now)
+		})
+		if err != nil {
+			apperror.Abort(c, apperror.FromDB(err, nil, "Failed to update habit log"))
+			return
+		}
+	}
+
+	database.DB.First(&habitLog, habitLog.ID)
+
+	c.Header("ETag", versionETag(habitLog.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Habit log updated successfully",
+		"data":    habitLog,
+	})
+}
+
+func DeleteHabitLog(c *gin.Context) {
+	_, habitLog, err := loadHabitLog(c)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	if err := checkIfMatch(c, habitLog.Version); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	now := time.Now()
+	if err := checkBackfillWindow("date", habitLog.Date, now); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var files []string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if files, err = deleteLogAttachments(tx, []uint{habitLog.ID}); err != nil {
+			return err
+		}
+		if err := tx.Where("habit_log_id = ?", habitLog.ID).Delete(&models.TimerSession{}).Error; err != nil {
+			return err
+		}
+		if err := versionedDelete(tx, &habitLog, habitLog.Version); err != nil {
+			return err
+		}
+		tombstone := newTombstone(&habitLog.UserID, audit.EntityHabitLog, habitLog.ID, habitLog.ClientID)
+		if err := tx.Create(&tombstone).Error; err != nil {
+			return err
+		}
+		if err := audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionDelete,
+			EntityType: audit.EntityHabitLog,
+			EntityID:   habitLog.ID,
+			Before:     habitLog,
+		}); err != nil {
+			return err
+		}
+		return logsChanged(tx, []uint{habitLog.HabitID}, now)
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to delete habit log"))
+		return
+	}
+	removeStoredFiles(files)
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Habit log deleted successfully",
+	})
+}
+
+// validateLogChange checks an edited log; a new date must stay within the
+// habit's lifetime and the backfill window
+func validateLogChange(habit *models.Habit, before, habitLog *models.HabitLog, now time.Time) error {
+	if err := applyHabitKind(habit, habitLog); err != nil {
+		return err
+	}
+	if err := validateHabitLog(habitLog); err != nil {
+		return err
+	}
+	if habitLog.Date.Equal(before.Date) {
+		return nil
+	}
+	return validateLogDate("date", habit, habitLog.Date, now)
+}
+
+// applyLogAmount recomputes completed from the habit's daily target when
+// only the amount changed
+func applyLogAmount(habit *models.Habit, habitLog *models.HabitLog, columns []string) []string {
+	if !containsColumn(columns, "amount") || containsColumn(columns, "completed") || containsColumn(columns, "status") {
+		return columns
+	}
+	habitLog.Completed = completedByAmount(habit, habitLog.Amount)
+	return append(columns, "completed")
+}
+
+// checkLogDayFree keeps one log per habit and day. before is the log as
+// stored when it is being edited, and nil for a new log. The habit is
+// locked so concurrent writes for the same day are checked one at a time.
+func checkLogDayFree(tx *gorm.DB, before, habitLog *models.HabitLog) error {
+	day := startOfDay(habitLog.Date)
+	if before != nil && day.Equal(startOfDay(before.Date)) {
+		return nil
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Habit{}, habitLog.HabitID).Error; err != nil {
+		return err
+	}
+
+	var count int64
+	if err := tx.Model(&models.HabitLog{}).
+		Where("habit_id = ? AND id <> ? AND date >= ? AND date < ?", habitLog.HabitID, habitLog.ID, day, day.AddDate(0, 0, 1)).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return apperror.ErrLogDayTaken
+	}
+	return nil
+}
+
+func containsColumn(columns []string, name string) bool {
+	for _, column := range columns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
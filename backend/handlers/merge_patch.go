@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"habit-tracker/apperror"
+)
+
+const MergePatchContentType = "application/merge-patch+json"
+
+// HabitPatch lists the fields a client may change with PATCH. Anything else
+// (id, user_id, created_at, relationships...) is rejected.
+type HabitPatch struct {
+	CategoryID         *uint      `json:"category_id"`
+	Name               *string    `json:"name"`
+	Description        *string    `json:"description"`
+	Kind               *string    `json:"kind"`
+	Unit               *string    `json:"unit"`
+	ChecklistThreshold *int       `json:"checklist_threshold"`
+	Color              *string    `json:"color"`
+	TimeOfDay          *string    `json:"time_of_day"`
+	Difficulty         *string    `json:"difficulty"`
+	IsActive           *bool      `json:"is_active"`
+	TargetPerDay       *int       `json:"target_per_day"`
+	StartDate          *time.Time `json:"start_date"`
+}
+
+// CategoryPatch lists the fields a client may change with PATCH
+type CategoryPatch struct {
+	ParentID *uint   `json:"parent_id"`
+	Name     *string `json:"name"`
+	Color    *string `json:"color"`
+}
+
+// HabitLogPatch lists the fields a client may change with PATCH
+type HabitLogPatch struct {
+	Date         *time.Time `json:"date"`
+	Completed    *bool      `json:"completed"`
+	Status       *string    `json:"status"`
+	SkipReason   *string    `json:"skip_reason"`
+	Amount       *int       `json:"amount"`
+	Note         *string    `json:"note"`
+	Mood         *int       `json:"mood"`
+	Effort       *int       `json:"effort"`
+	Tags         *[]string  `json:"tags"`
+	CheckedItems *[]uint    `json:"checked_items"`
+}
+
+// bindMergePatch applies the RFC 7396 merge patch in the request body to
+// target (a pointer to a model) and returns the patched columns. Model JSON
+// field names are their column names, so the keys are used as-is.
+func bindMergePatch(c *gin.Context, target interface{}, patchDoc interface{}) ([]string, error) {
+	contentType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if contentType != MergePatchContentType && contentType != "application/json" {
+		return nil, apperror.New(http.StatusUnsupportedMediaType, apperror.CodeUnsupportedMedia, "Content-Type must be "+MergePatchContentType)
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, apperror.Validation(err)
+	}
+
+	var patch map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&patch); err != nil || patch == nil {
+		return nil, apperror.BadRequest(apperror.CodeInvalidJSON, "Merge patch must be a JSON object")
+	}
+
+	return applyMergePatch(target, patch, patchDoc)
+}
+
+// applyMergePatch applies an already decoded merge patch to target, allowing
+// only the fields of patchDoc
+func applyMergePatch(target interface{}, patch map[string]interface{}, patchDoc interface{}) ([]string, error) {
+	allowed := jsonFieldNames(patchDoc)
+	current, err := toJSONMap(target)
+	if err != nil {
+		return nil, apperror.Internal("Failed to apply patch", err)
+	}
+
+	var rejected *apperror.Error
+	columns := make([]string, 0, len(patch))
+	for key := range patch {
+		if allowed[key] {
+			columns = append(columns, key)
+			continue
+		}
+		if rejected == nil {
+			rejected = apperror.BadRequest(apperror.CodeValidationFailed, "Patch contains fields that cannot be modified")
+		}
+		if _, exists := current[key]; exists {
+			rejected = rejected.WithField(key, "cannot be modified")
+		} else {
+			rejected = rejected.WithField(key, "unknown field")
+		}
+	}
+	if rejected != nil {
+		return nil, rejected
+	}
+	sort.Strings(columns)
+
+	merged, err := json.Marshal(mergePatch(current, patch))
+	if err != nil {
+		return nil, apperror.Internal("Failed to apply patch", err)
+	}
+
+	// Decode into a zero value so members removed with null end up zeroed
+	fresh := reflect.New(reflect.TypeOf(target).Elem())
+	if err := json.Unmarshal(merged, fresh.Interface()); err != nil {
+		return nil, apperror.Validation(err)
+	}
+	reflect.ValueOf(target).Elem().Set(fresh.Elem())
+
+	return columns, nil
+}
+
+// mergePatch implements the MergePatch algorithm from RFC 7396 section 2
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+		} else {
+			targetObject[key] = mergePatch(targetObject[key], value)
+		}
+	}
+	return targetObject
+}
+
+func toJSONMap(v interface{}) (map[string]interface{}, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// patchColumns returns the columns named by a patch document, sorted
+func patchColumns(patchDoc interface{}) []string {
+	var columns []string
+	for name := range jsonFieldNames(patchDoc) {
+		columns = append(columns, name)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+func jsonFieldNames(v interface{}) map[string]bool {
+	names := make(map[string]bool)
+	t := reflect.TypeOf(v)
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
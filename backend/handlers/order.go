@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"habit-tracker/apperror"
+	"habit-tracker/audit"
+	"habit-tracker/database"
+	"habit-tracker/models"
+)
+
+// HabitOrderRequest lists habits in their new order
+type HabitOrderRequest struct {
+	HabitIDs []uint `json:"habit_ids" binding:"required,min=1"`
+}
+
+// CategoryOrderRequest lists categories in their new order
+type CategoryOrderRequest struct {
+	CategoryIDs []uint `json:"category_ids" binding:"required,min=1"`
+}
+
+// ReorderHabits puts the listed habits in the given order. They take the
+// positions they held between them, so a client can reorder one section
+// without sending every habit; habits not listed keep their place.
+func ReorderHabits(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req HabitOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(c, apperror.Validation(err))
+		return
+	}
+	if len(uniqueIDs(req.HabitIDs)) != len(req.HabitIDs) {
+		apperror.Abort(c, apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed").
+			WithField("habit_ids", "must not contain duplicates"))
+		return
+	}
+
+	var habits []models.Habit
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var current []models.Habit
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ? AND user_id = ?", req.HabitIDs, userID).Find(&current).Error; err != nil {
+			return err
+		}
+		if len(current) != len(req.HabitIDs) {
+			return apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed").
+				WithField("habit_ids", "contains unknown habits")
+		}
+
+		byID := make(map[uint]models.Habit, len(current))
+		positions := make([]int, 0, len(current))
+		for _, habit := range current {
+			byID[habit.ID] = habit
+			positions = append(positions, habit.Position)
+		}
+		sort.Ints(positions)
+
+		now := time.Now()
+		for i, id := range req.HabitIDs {
+			habit := byID[id]
+			if habit.Position != positions[i] {
+				before := habit
+				habit.Position = positions[i]
+				habit.UpdatedAt = now
+				habit.Version = before.Version + 1
+				if err := versionedUpdate(tx, &before, before.Version, []string{"position", "updated_at"}, &habit); err != nil {
+					return err
+				}
+				if err := audit.Record(tx, c, audit.Entry{
+					Action:     audit.ActionUpdate,
+					EntityType: audit.EntityHabit,
+					EntityID:   habit.ID,
+					Before:     before,
+					After:      habit,
+				}); err != nil {
+					return err
+				}
+			}
+			habits = append(habits, habit)
+		}
+		return nil
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to reorder habits"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Habits reordered successfully",
+		"data":    habits,
+	})
+}
+
+// ReorderCategories puts the listed categories in the given order, the
+// same way ReorderHabits does. The tree is ordered by position within
+// each parent.
+func ReorderCategories(c *gin.Context) {
+	var req CategoryOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(c, apperror.Validation(err))
+		return
+	}
+	if len(uniqueIDs(req.CategoryIDs)) != len(req.CategoryIDs) {
+		apperror.Abort(c, apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed").
+			WithField("category_ids", "must not contain duplicates"))
+		return
+	}
+
+	var categories []models.HabitCategory
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var current []models.HabitCategory
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", req.CategoryIDs).Find(&current).Error; err != nil {
+			return err
+		}
+		if len(current) != len(req.CategoryIDs) {
+			return apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed").
+				WithField("category_ids", "contains unknown categories")
+		}
+
+		byID := make(map[uint]models.HabitCategory, len(current))
+		positions := make([]int, 0, len(current))
+		for _, category := range current {
+			byID[category.ID] = category
+			positions = append(positions, category.Position)
+		}
+		sort.Ints(positions)
+
+		now := time.Now()
+		for i, id := range req.CategoryIDs {
+			category := byID[id]
+			if category.Position != positions[i] {
+				before := category
+				category.Position = positions[i]
+				category.UpdatedAt = now
+				category.Version = before.Version + 1
+				if err := versionedUpdate(tx, &before, before.Version, []string{"position", "updated_at"}, &category); err != nil {
+					return err
+				}
+				if err := audit.Record(tx, c, audit.Entry{
+					Action:     audit.ActionUpdate,
+					EntityType: audit.EntityCategory,
+					EntityID:   category.ID,
+					Before:     before,
+					After:      category,
+				}); err != nil {
+					return err
+				}
+			}
+			categories = append(categories, category)
+		}
+		return nil
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to reorder categories"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Categories reordered successfully",
+		"data":    categories,
+	})
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"habit-tracker/apperror"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 500
+)
+
+type columnKind int
+
+const (
+	kindString columnKind = iota
+	kindInt
+	kindBool
+	kindTime
+)
+
+// sortableColumns maps a sortable column to its type. Column names must match
+// the model's JSON field names so cursor values can be read from the response.
+type sortableColumns map[string]columnKind
+
+type sortField struct {
+	Column string
+	Desc   bool
+	Kind   columnKind
+}
+
+type listParams struct {
+	Limit int
+	Sort  []sortField
+	sort  string
+
+	// Position after which the requested page starts, nil for the first page
+	cursorValues []interface{}
+	cursorID     uint
+}
+
+type pageCursor struct {
+	Sort   string            `json:"s"`
+	Values []json.RawMessage `json:"v"`
+	ID     uint              `json:"id"`
+}
+
+// Pagination is returned next to "data" by every list endpoint
+type Pagination struct {
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// parseListParams reads limit, sort and cursor from the query string.
+// defaultSort uses the same syntax as the sort parameter, e.g. "-created_at".
+func parseListParams(c *gin.Context, allowed sortableColumns, defaultSort string) (*listParams, error) {
+	params := &listParams{Limit: defaultPageLimit}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return nil, apperror.InvalidParam("limit", "limit must be a positive integer")
+		}
+		if limit > maxPageLimit {
+			limit = maxPageLimit
+		}
+		params.Limit = limit
+	}
+
+	params.sort = c.DefaultQuery("sort", defaultSort)
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(params.sort, ",") {
+		part = strings.TrimSpace(part)
+		field := sortField{Column: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		kind, ok := allowed[field.Column]
+		if !ok {
+			return nil, apperror.InvalidParam("sort", fmt.Sprintf("cannot sort by %q", field.Column))
+		}
+		if seen[field.Column] {
+			return nil, apperror.InvalidParam("sort", fmt.Sprintf("duplicate sort field %q", field.Column))
+		}
+		seen[field.Column] = true
+		field.Kind = kind
+		params.Sort = append(params.Sort, field)
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		cursor, err := decodeCursor(raw)
+		if err != nil || cursor.Sort != params.sort || len(cursor.Values) != len(params.Sort) {
+			return nil, apperror.InvalidParam("cursor", "invalid cursor")
+		}
+		for i, field := range params.Sort {
+			value, err := parseCursorValue(field.Kind, cursor.Values[i])
+			if err != nil {
+				return nil, apperror.InvalidParam("cursor", "invalid cursor")
+			}
+			params.cursorValues = append(params.cursorValues, value)
+		}
+		params.cursorID = cursor.ID
+	}
+
+	return params, nil
+}
+
+// apply adds ordering, the cursor condition and the limit to query. One extra
+// row is requested so paginate can tell whether another page exists.
+func (p *listParams) apply(query *gorm.DB) *gorm.DB {
+	if p.cursorValues != nil {
+		// (a > ?) OR (a = ? AND b > ?) OR ... OR (a = ? AND b = ? AND id > ?)
+		var clauses []string
+		var args []interface{}
+		for i := 0; i <= len(p.Sort); i++ {
+			var parts []string
+			for j := 0; j < i; j++ {
+				parts = append(parts, p.Sort[j].Column+" = ?")
+				args = append(args, p.cursorValues[j])
+			}
+			if i < len(p.Sort) {
+				op := ">"
+				if p.Sort[i].Desc {
+					op = "<"
+				}
+				parts = append(parts, p.Sort[i].Column+" "+op+" ?")
+				args = append(args, p.cursorValues[i])
+			} else {
+				parts = append(parts, "id > ?")
+				args = append(args, p.cursorID)
+			}
+			clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+		}
+		query = query.Where(strings.Join(clauses, " OR "), args...)
+	}
+
+	for _, field := range p.Sort {
+		direction := "ASC"
+		if field.Desc {
+			direction = "DESC"
+		}
+		query = query.Order(field.Column + " " + direction)
+	}
+
+	return query.Order("id" + " ASC").Limit(p.Limit + 1)
+}
+
+// paginate trims the extra row fetched by apply and builds the next cursor
+// from the last row that is returned.
+func paginate[T any](p *listParams, rows []T) ([]T, Pagination, error) {
+	page := Pagination{Limit: p.Limit}
+	if len(rows) <= p.Limit {
+		return rows, page, nil
+	}
+
+	rows = rows[:p.Limit]
+	cursor, err := p.cursorFor(rows[len(rows)-1])
+	if err != nil {
+		return nil, page, err
+	}
+	page.NextCursor = cursor
+	page.HasMore = true
+	return rows, page, nil
+}
+
+func (p *listParams) cursorFor(row interface{}) (string, error) {
+	raw, err := json.Marshal(row)
+	if err != nil {
+		return "", err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return "", err
+	}
+
+	cursor := pageCursor{Sort: p.sort}
+	if err := json.Unmarshal(fields["id"], &cursor.ID); err != nil {
+		return "", err
+	}
+	for _, field := range p.Sort {
+		value, ok := fields[field.Column]
+		if !ok {
+			return "", fmt.Errorf("missing sort field %q", field.Column)
+		}
+		cursor.Values = append(cursor.Values, value)
+	}
+
+	encoded, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(encoded), nil
+}
+
+func decodeCursor(raw string) (*pageCursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor pageCursor
+	if err := json.Unmarshal(decoded, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+func parseCursorValue(kind columnKind, raw json.RawMessage) (interface{}, error) {
+	var err error
+	switch kind {
+	case kindInt:
+		var value int64
+		err = json.Unmarshal(raw, &value)
+		return value, err
+	case kindBool:
+		var value bool
+		err = json.Unmarshal(raw, &value)
+		return value, err
+	case kindTime:
+		var value time.Time
+		err = json.Unmarshal(raw, &value)
+		return value, err
+	default:
+		var value string
+		err = json.Unmarshal(raw, &value)
+		return value, err
+	}
+}
+
+// escapeLike escapes the wildcard characters of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(s)
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"habit-tracker/apperror"
+)
+
+// parseIDParam reads a positive numeric path parameter
+func parseIDParam(c *gin.Context, name, message string) (uint, error) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil || id == 0 {
+		return 0, apperror.InvalidParam(name, message)
+	}
+	return uint(id), nil
+}
+
+// parseIDQuery reads an optional numeric query parameter, returning nil when absent
+func parseIDQuery(c *gin.Context, name, message string) (*uint, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil || id == 0 {
+		return nil, apperror.InvalidParam(name, message)
+	}
+	value := uint(id)
+	return &value, nil
+}
+
+// parseIDListQuery reads an optional comma separated list of IDs
+func parseIDListQuery(c *gin.Context, name, message string) ([]uint, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	var ids []uint
+	for _, part := range strings.Split(raw, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 32)
+		if err != nil || id == 0 {
+			return nil, apperror.InvalidParam(name, message)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+// parseBoolQuery returns nil when the parameter is absent
+func parseBoolQuery(c *gin.Context, key string) (*bool, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, apperror.InvalidParam(key, key+" must be true or false")
+	}
+	return &value, nil
+}
+
+// parseIntQuery reads an optional integer query parameter within [min, max]
+func parseIntQuery(c *gin.Context, key string, min, max int) (*int, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < min || value > max {
+		return nil, apperror.InvalidParam(key, fmt.Sprintf("%s must be an integer between %d and %d", key, min, max))
+	}
+	return &value, nil
+}
+
+// parseDateQuery accepts YYYY-MM-DD and returns nil when the parameter is absent
+func parseDateQuery(c *gin.Context, key string) (*time.Time, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := time.ParseInLocation("2006-01-02", raw, time.Local)
+	if err != nil {
+		return nil, apperror.InvalidParam(key, key+" must be a date in YYYY-MM-DD format")
+	}
+	return &value, nil
+}
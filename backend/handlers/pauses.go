@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"habit-tracker/apperror"
+	"habit-tracker/audit"
+	"habit-tracker/database"
+	"habit-tracker/models"
+)
+
+// autoResumeJob names the background job in audit entries
+const autoResumeJob = "auto-resume"
+
+var habitPauseSortColumns = sortableColumns{
+	"id":         kindInt,
+	"paused_at":  kindTime,
+	"created_at": kindTime,
+}
+
+// PauseRequest pauses a habit, until ResumeOn when it is set
+type PauseRequest struct {
+	ResumeOn string `json:"resume_on" binding:"omitempty,datetime=2006-01-02"`
+	Reason   string `json:"reason" binding:"max=255"`
+}
+
+// GetHabitPauses lists the pause intervals of a habit
+func GetHabitPauses(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	habitID, err := parseIDParam(c, "id", "Invalid habit ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var habit models.Habit
+	if err := database.DB.Where("id = ? AND user_id = ?", habitID, userID).First(&habit).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errHabitNotFound, "Failed to fetch habit"))
+		return
+	}
+
+	params, err := parseListParams(c, habitPauseSortColumns, "-paused_at")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var pauses []models.HabitPause
+	if err := params.apply(database.DB.Where("habit_id = ?", habit.ID)).Find(&pauses).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch habit pauses"))
+		return
+	}
+
+	pauses, page, err := paginate(params, pauses)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal("Failed to build pagination cursor", err))
+		return
+	}
+
+	respondList(c, "Habit pauses retrieved successfully", pauses, page)
+}
+
+// PauseHabit deactivates a habit and opens a pause interval. The body is
+// optional; with resume_on the habit is resumed at the start of that day.
+func PauseHabit(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := parseIDParam(c, "id", "Invalid habit ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var req PauseRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		apperror.Abort(c, apperror.Validation(err))
+		return
+	}
+
+	now := time.Now()
+	pause := models.HabitPause{PausedAt: now, Reason: req.Reason}
+	if req.ResumeOn != "" {
+		// The date passed the binding's format check
+		resumeAt, _ := time.ParseInLocation("2006-01-02", req.ResumeOn, time.Local)
+		if !resumeAt.After(startOfDay(now)) {
+			apperror.Abort(c, apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed").WithField("resume_on", "must be after today"))
+			return
+		}
+		pause.ResumeAt = &resumeAt
+	}
+
+	var habit models.Habit
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&habit).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errHabitNotFound, "Failed to fetch habit"))
+		return
+	}
+
+	if err := checkIfMatch(c, habit.Version); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if !habit.IsActive {
+		apperror.Abort(c, apperror.Conflict(apperror.CodeHabitPaused, "Habit is already paused"))
+		return
+	}
+
+	before := habit
+	habit.IsActive = false
+	habit.Version = before.Version + 1
+	pause.UserID = habit.UserID
+	pause.HabitID = habit.ID
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := versionedUpdate(tx, &before, before.Version, []string{"is_active"}, &habit); err != nil {
+			return err
+		}
+		if err := tx.Create(&pause).Error; err != nil {
+			return err
+		}
+		if err := habitsChanged(tx, []uint{habit.ID}, time.Now()); err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionUpdate,
+			EntityType: audit.EntityHabit,
+			EntityID:   habit.ID,
+			Before:     before,
+			After:      habit,
+		})
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to pause habit"))
+		return
+	}
+
+	c.Header("ETag", versionETag(habit.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Habit paused successfully",
+		"data":    habit,
+	})
+}
+
+// ResumeHabit reactivates a paused habit and closes its pause interval
+func ResumeHabit(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := parseIDParam(c, "id", "Invalid habit ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var habit models.Habit
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&habit).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errHabitNotFound, "Failed to fetch habit"))
+		return
+	}
+
+	if err := checkIfMatch(c, habit.Version); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if habit.IsActive {
+		apperror.Abort(c, apperror.Conflict(apperror.CodeHabitNotPaused, "Habit is not paused"))
+		return
+	}
+
+	before := habit
+	habit.IsActive = true
+	habit.Version = before.Version + 1
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := versionedUpdate(tx, &before, before.Version, []string{"is_active"}, &habit); err != nil {
+			return err
+		}
+		if err := endPause(tx, habit.ID, time.Now()); err != nil {
+			return err
+		}
+		if err := habitsChanged(tx, []uint{habit.ID}, time.Now()); err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionUpdate,
+			EntityType: audit.EntityHabit,
+			EntityID:   habit.ID,
+			Before:     before,
+			After:      habit,
+		})
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to resume habit"))
+		return
+	}
+
+	c.Header("ETag", versionETag(habit.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Habit resumed successfully",
+		"data":    habit,
+	})
+}
+
+// recordPauseChange opens or closes a pause interval when an update flips
+// is_active, so toggles and edits leave the same history as pause and resume
+func recordPauseChange(tx *gorm.DB, before, habit *models.Habit, now time.Time) error {
+	switch {
+	case before.IsActive && !habit.IsActive:
+		return tx.Create(&models.HabitPause{UserID: habit.UserID, HabitID: habit.ID, PausedAt: now}).Error
+	case !before.IsActive && habit.IsActive:
+		return endPause(tx, habit.ID, now)
+	}
+	return nil
+}
+
+// endPause closes the open pause interval of a habit, if there is one.
+// Habits deactivated before pauses were recorded have none.
+func endPause(tx *gorm.DB, habitID uint, now time.Time) error {
+	return tx.Model(&models.HabitPause{}).
+		Where("habit_id = ? AND resumed_at IS NULL", habitID).
+		Update("resumed_at", now).Error
+}
+
+// AutoResumeHabits resumes the habits whose scheduled resume date has
+// passed. It runs as a background job; a habit that fails is logged and
+// retried on the next run.
+func AutoResumeHabits(now time.Time) error {
+	var pauses []models.HabitPause
+	if err := database.DB.Where("resumed_at IS NULL AND resume_at <= ?", now).Find(&pauses).Error; err != nil {
+		return err
+	}
+
+	for _, pause := range pauses {
+		if err := autoResume(pause.ID); err != nil {
+			log.Printf("Failed to auto-resume habit %d: %v", pause.HabitID, err)
+		}
+	}
+	return nil
+}
+
+func autoResume(pauseID uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the pause so concurrent runs resume it only once
+		var pause models.HabitPause
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND resumed_at IS NULL", pauseID).First(&pause).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Close the interval at the scheduled time even when the job runs late
+		if err := tx.Model(&pause).Update("resumed_at", pause.ResumeAt).Error; err != nil {
+			return err
+		}
+
+		var habit models.Habit
+		if err := tx.First(&habit, pause.HabitID).Error; err != nil {
+			return err
+		}
+		if habit.IsActive {
+			return nil
+		}
+
+		before := habit
+		habit.IsActive = true
+		habit.Version = before.Version + 1
+		if err := versionedUpdate(tx, &before, before.Version, []string{"is_active"}, &habit); err != nil {
+			return err
+		}
+		if err := habitsChanged(tx, []uint{habit.ID}, time.Now()); err != nil {
+			return err
+		}
+		return audit.RecordSystem(tx, autoResumeJob, audit.Entry{
+			ActorID:    habit.UserID,
+			Action:     audit.ActionUpdate,
+			EntityType: audit.EntityHabit,
+			EntityID:   habit.ID,
+			Before:     before,
+			After:      habit,
+		})
+	})
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"habit-tracker/apperror"
+	"habit-tracker/database"
+	"habit-tracker/models"
+	"habit-tracker/stats"
+)
+
+var errQuitLogStatus = apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed").
+	WithField("status", "logs of a quit habit record relapses and must be failed")
+
+// RelapseStats reports on a quit habit. Clean runs are the habit's streaks:
+// days before today without a relapse, where days off neither count nor
+// break a run. The totals and weekly trend cover from..to.
+type RelapseStats struct {
+	DaysClean       int           `json:"days_clean"`
+	LongestCleanRun int           `json:"longest_clean_run"`
+	LastRelapse     *string       `json:"last_relapse"`
+	RelapseDays     int           `json:"relapse_days"`
+	Relapses        int           `json:"relapses"`
+	RelapsesPerWeek float64       `json:"relapses_per_week"`
+	Weekly          []RelapseWeek `json:"weekly"`
+}
+
+// RelapseWeek is the number of relapses in the week starting on Monday Week
+type RelapseWeek struct {
+	Week     string `json:"week"`
+	Relapses int    `json:"relapses"`
+}
+
+// applyHabitKind adapts a log to its habit's kind. A log of a quit habit
+// records a relapse: it must be failed and counts at least one relapse.
+func applyHabitKind(habit *models.Habit, habitLog *models.HabitLog) error {
+	if habit.Kind != models.HabitKindQuit {
+		return nil
+	}
+	if habitLog.Status != models.LogStatusFailed {
+		return errQuitLogStatus
+	}
+	if habitLog.Amount < 1 {
+		habitLog.Amount = 1
+	}
+	return nil
+}
+
+// completedByAmount reports whether amount meets the habit's daily target.
+// The amount of a quit habit's log counts relapses, so it never completes.
+func completedByAmount(habit *models.Habit, amount int) bool {
+	return habit.Kind != models.HabitKindQuit && amount >= habit.TargetPerDay
+}
+
+// checkKindChange rejects changing the kind of a habit that has logs, whose
+// meaning would flip
+func checkKindChange(db *gorm.DB, before, habit *models.Habit) error {
+	if habit.Kind == before.Kind {
+		return nil
+	}
+	var count int64
+	if err := db.Model(&models.HabitLog{}).Where("habit_id = ?", before.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed").
+			WithField("kind", "cannot be changed once the habit has logs")
+	}
+	return nil
+}
+
+// relapseStats summarises the relapses of a quit habit up to today. overall
+// summarises the habit's whole calendar.
+func relapseStats(habit *models.Habit, from, to, today time.Time, overall stats.Summary) (*RelapseStats, error) {
+	var relapses []models.HabitLog
+	if err := database.DB.Select("date", "amount").
+		Where("habit_id = ? AND status = ? AND date < ?", habit.ID, models.LogStatusFailed, today.AddDate(0, 0, 1)).
+		Order("date").Find(&relapses).Error; err != nil {
+		return nil, err
+	}
+
+	result := &RelapseStats{
+		DaysClean:       overall.CurrentStreak,
+		LongestCleanRun: overall.LongestStreak,
+		Weekly:          []RelapseWeek{},
+	}
+	weekly := make(map[string]int)
+	for _, relapse := range relapses {
+		day := startOfDay(relapse.Date)
+		if day.Before(from) || day.After(to) {
+			continue
+		}
+		result.RelapseDays++
+		result.Relapses += relapse.Amount
+		weekly[startOfWeek(day).Format("2006-01-02")] += relapse.Amount
+	}
+	if len(relapses) > 0 {
+		date := startOfDay(relapses[len(relapses)-1].Date).Format("2006-01-02")
+		result.LastRelapse = &date
+	}
+
+	for week := startOfWeek(from); !week.After(to); week = week.AddDate(0, 0, 7) {
+		key := week.Format("2006-01-02")
+		result.Weekly = append(result.Weekly, RelapseWeek{Week: key, Relapses: weekly[key]})
+	}
+	if len(result.Weekly) > 0 {
+		perWeek := float64(result.Relapses) / float64(len(result.Weekly))
+		result.RelapsesPerWeek = math.Round(perWeek*100) / 100
+	}
+	return result, nil
+}
+
+// daysBetween counts the calendar days from a to b, both midnights
+func daysBetween(a, b time.Time) int {
+	return int(math.Round(b.Sub(a).Hours() / 24))
+}
+
+// startOfWeek returns the Monday of day's week
+func startOfWeek(day time.Time) time.Time {
+	offset := (int(day.Weekday()) + 6) % 7
+	return startOfDay(day).AddDate(0, 0, -offset)
+}
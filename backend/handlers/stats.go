@@ -0,0 +1,510 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"habit-tracker/apperror"
+	"habit-tracker/database"
+	"habit-tracker/models"
+	"habit-tracker/stats"
+)
+
+// Reasons a day is neutral, reported per day by the stats endpoint
+const (
+	NeutralSkipped  = "skipped"
+	NeutralPaused   = "paused"
+	NeutralVacation = "vacation"
+	NeutralFrozen   = "frozen"
+)
+
+// CalendarDay is the state of a habit on one day
+type CalendarDay struct {
+	Date   string      `json:"date"`
+	State  stats.State `json:"-"`
+	Status string      `json:"status"`
+	Reason string      `json:"reason,omitempty"`
+}
+
+// HabitStats summarises a habit over a period. Streaks always cover the
+// habit's whole history; the totals cover from..to.
+type HabitStats struct {
+	stats.Summary
+	From         string        `json:"from"`
+	To           string        `json:"to"`
+	SkippedDays  int           `json:"skipped_days"`
+	PausedDays   int           `json:"paused_days"`
+	VacationDays int           `json:"vacation_days"`
+	FrozenDays   int           `json:"frozen_days"`
+	Relapses     *RelapseStats `json:"relapses,omitempty"`
+	Items        []ItemStats   `json:"items,omitempty"`
+	Days         []CalendarDay `json:"days"`
+}
+
+// OverviewStats sums the completion of several habits over from..to.
+// Streaks are per habit and only reported in Habits.
+type OverviewStats struct {
+	From           string         `json:"from"`
+	To             string         `json:"to"`
+	CompletedDays  int            `json:"completed_days"`
+	MissedDays     int            `json:"missed_days"`
+	DueDays        int            `json:"due_days"`
+	CompletionRate float64        `json:"completion_rate"`
+	Habits         []HabitSummary `json:"habits"`
+}
+
+// HabitSummary is one habit's part of OverviewStats
+type HabitSummary struct {
+	HabitID uint   `json:"habit_id"`
+	Name    string `json:"name"`
+	stats.Summary
+}
+
+// defaultOverviewDays is the period of the stats across habits when from is
+// not given
+const defaultOverviewDays = 30
+
+// GetStatsOverview summarises the user's current habits, optionally only
+// those in a category and its subcategories or with any or all of the given
+// tags. The period defaults to the last 30 days.
+func GetStatsOverview(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	today := startOfDay(time.Now())
+	windowFrom, windowTo, err := parseStatsPeriod(c, today)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	query := database.DB.Where("user_id = ? AND archived_at IS NULL", userID)
+	categoryID, err := parseIDQuery(c, "category_id", "Invalid category ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if categoryID != nil {
+		// A category's stats roll up those of its subcategories
+		subtree, err := categorySubtree(database.DB, *categoryID)
+		if err != nil {
+			apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch categories"))
+			return
+		}
+		query = query.Where("category_id IN ?", subtree)
+	}
+	tags, err := parseTagFilter(c)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var habits []models.Habit
+	if err := tags.apply(query).Order("id").Find(&habits).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch habits"))
+		return
+	}
+
+	result := OverviewStats{
+		From:   windowFrom.Format("2006-01-02"),
+		To:     windowTo.Format("2006-01-02"),
+		Habits: []HabitSummary{},
+	}
+	for i := range habits {
+		habit := &habits[i]
+		summary, err := habitSummary(habit, windowFrom, windowTo, today)
+		if err != nil {
+			apperror.Abort(c, apperror.FromDB(err, nil, "Failed to calculate stats"))
+			return
+		}
+
+		result.Habits = append(result.Habits, HabitSummary{HabitID: habit.ID, Name: habit.Name, Summary: summary})
+		result.CompletedDays += summary.CompletedDays
+		result.MissedDays += summary.MissedDays
+	}
+	result.DueDays = result.CompletedDays + result.MissedDays
+	if result.DueDays > 0 {
+		result.CompletionRate = float64(result.CompletedDays) / float64(result.DueDays)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Stats retrieved successfully",
+		"data":    result,
+	})
+}
+
+// CategoryStats sums the completion of the user's current habits in a
+// category and all its subcategories, over from..to
+type CategoryStats struct {
+	CategoryID     uint            `json:"category_id"`
+	ParentID       *uint           `json:"parent_id"`
+	Name           string          `json:"name"`
+	Habits         int             `json:"habits"`
+	CompletedDays  int             `json:"completed_days"`
+	MissedDays     int             `json:"missed_days"`
+	DueDays        int             `json:"due_days"`
+	CompletionRate float64         `json:"completion_rate"`
+	Children       []CategoryStats `json:"children"`
+}
+
+// GetCategoryStats returns the category tree with the completion of each
+// category rolled up from its subcategories. The period defaults to the
+// last 30 days.
+func GetCategoryStats(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	today := startOfDay(time.Now())
+	windowFrom, windowTo, err := parseStatsPeriod(c, today)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var categories []models.HabitCategory
+	if err := database.DB.Order("position").Order("id").Find(&categories).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch categories"))
+		return
+	}
+
+	var habits []models.Habit
+	if err := database.DB.Where("user_id = ? AND archived_at IS NULL AND category_id IS NOT NULL", userID).
+		Find(&habits).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch habits"))
+		return
+	}
+
+	// Totals of the habits directly in each category
+	own := make(map[uint]*CategoryStats)
+	for i := range habits {
+		habit := &habits[i]
+		summary, err := habitSummary(habit, windowFrom, windowTo, today)
+		if err != nil {
+			apperror.Abort(c, apperror.FromDB(err, nil, "Failed to calculate stats"))
+			return
+		}
+		totals := own[*habit.CategoryID]
+		if totals == nil {
+			totals = &CategoryStats{}
+			own[*habit.CategoryID] = totals
+		}
+		totals.Habits++
+		totals.CompletedDays += summary.CompletedDays
+		totals.MissedDays += summary.MissedDays
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Category stats retrieved successfully",
+		"data":    rollUpCategoryStats(buildCategoryTree(categories), own),
+	})
+}
+
+// rollUpCategoryStats adds the totals of every category's subtree to it
+func rollUpCategoryStats(tree []models.HabitCategory, own map[uint]*CategoryStats) []CategoryStats {
+	result := make([]CategoryStats, 0, len(tree))
+	for _, category := range tree {
+		node := CategoryStats{CategoryID: category.ID, ParentID: category.ParentID, Name: category.Name}
+		if totals := own[category.ID]; totals != nil {
+			node.Habits, node.CompletedDays, node.MissedDays = totals.Habits, totals.CompletedDays, totals.MissedDays
+		}
+		node.Children = rollUpCategoryStats(category.Children, own)
+		for _, child := range node.Children {
+			node.Habits += child.Habits
+			node.CompletedDays += child.CompletedDays
+			node.MissedDays += child.MissedDays
+		}
+		node.DueDays = node.CompletedDays + node.MissedDays
+		if node.DueDays > 0 {
+			node.CompletionRate = float64(node.CompletedDays) / float64(node.DueDays)
+		}
+		result = append(result, node)
+	}
+	return result
+}
+
+// parseStatsPeriod reads the from and to query parameters of the stats that
+// span several habits. The period defaults to the last 30 days and ends
+// today at the latest.
+func parseStatsPeriod(c *gin.Context, today time.Time) (time.Time, time.Time, error) {
+	from, err := parseDateQuery(c, "from")
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to, err := parseDateQuery(c, "to")
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	windowFrom, windowTo := today.AddDate(0, 0, 1-defaultOverviewDays), today
+	if from != nil {
+		windowFrom = *from
+	}
+	if to != nil && to.Before(windowTo) {
+		windowTo = *to
+	}
+	return windowFrom, windowTo, nil
+}
+
+// habitSummary summarises a habit over from..to. Streaks cover the habit's
+// whole history up to today.
+func habitSummary(habit *models.Habit, from, to, today time.Time) (stats.Summary, error) {
+	start := startOfDay(habit.StartDate)
+	days, err := habitCalendar(database.DB, habit, start, today)
+	if err != nil {
+		return stats.Summary{}, err
+	}
+
+	all := make([]stats.State, len(days))
+	var window []stats.State
+	for i, day := range days {
+		all[i] = day.State
+		date := start.AddDate(0, 0, i)
+		if !date.Before(from) && !date.After(to) {
+			window = append(window, day.State)
+		}
+	}
+	overall := stats.Summarize(all)
+	summary := stats.Summarize(window)
+	summary.CurrentStreak = overall.CurrentStreak
+	summary.LongestStreak = overall.LongestStreak
+	return summary, nil
+}
+
+func GetHabitStats(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	habitID, err := parseIDParam(c, "id", "Invalid habit ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var habit models.Habit
+	if err := database.DB.Where("id = ? AND user_id = ?", habitID, userID).First(&habit).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errHabitNotFound, "Failed to fetch habit"))
+		return
+	}
+
+	// An archived habit's history ends on the day it was archived
+	today := startOfDay(time.Now())
+	if habit.ArchivedAt != nil {
+		today = startOfDay(*habit.ArchivedAt)
+	}
+	start := startOfDay(habit.StartDate)
+
+	from, err := parseDateQuery(c, "from")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	to, err := parseDateQuery(c, "to")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	windowFrom, windowTo := start, today
+	if from != nil && from.After(windowFrom) {
+		windowFrom = *from
+	}
+	if to != nil && to.Before(windowTo) {
+		windowTo = *to
+	}
+
+	days, err := habitCalendar(database.DB, &habit, start, today)
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to calculate stats"))
+		return
+	}
+
+	states := make([]stats.State, len(days))
+	for i, day := range days {
+		states[i] = day.State
+	}
+	overall := stats.Summarize(states)
+
+	result := HabitStats{
+		From: windowFrom.Format("2006-01-02"),
+		To:   windowTo.Format("2006-01-02"),
+		Days: []CalendarDay{},
+	}
+	var window []stats.State
+	for i, day := range days {
+		date := start.AddDate(0, 0, i)
+		if date.Before(windowFrom) || date.After(windowTo) {
+			continue
+		}
+		window = append(window, day.State)
+		result.Days = append(result.Days, day)
+		switch day.Reason {
+		case NeutralSkipped:
+			result.SkippedDays++
+		case NeutralPaused:
+			result.PausedDays++
+		case NeutralVacation:
+			result.VacationDays++
+		case NeutralFrozen:
+			result.FrozenDays++
+		}
+	}
+	result.Summary = stats.Summarize(window)
+	result.CurrentStreak = overall.CurrentStreak
+	result.LongestStreak = overall.LongestStreak
+
+	if result.Items, err = checklistStats(&habit, windowFrom, windowTo, result.DueDays); err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to calculate stats"))
+		return
+	}
+	if habit.Kind == models.HabitKindQuit {
+		if result.Relapses, err = relapseStats(&habit, windowFrom, windowTo, today, overall); err != nil {
+			apperror.Abort(c, apperror.FromDB(err, nil, "Failed to calculate stats"))
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Habit stats retrieved successfully",
+		"data":    result,
+	})
+}
+
+// habitCalendar returns the state of the habit on every day from from to to,
+// as decided by calendarSource.days. Reads go through db, so a transaction
+// sees its own writes.
+func habitCalendar(db *gorm.DB, habit *models.Habit, from, to time.Time) ([]CalendarDay, error) {
+	end := to.AddDate(0, 0, 1)
+	source := calendarSource{statuses: make(map[string]string)}
+
+	var habitLogs []models.HabitLog
+	if err := db.Select("date", "status").
+		Where("habit_id = ? AND date >= ? AND date < ?", habit.ID, from, end).
+		Find(&habitLogs).Error; err != nil {
+		return nil, err
+	}
+	// With several logs on a day, completed beats skipped beats failed
+	rank := map[string]int{models.LogStatusFailed: 1, models.LogStatusSkipped: 2, models.LogStatusCompleted: 3}
+	for _, habitLog := range habitLogs {
+		key := startOfDay(habitLog.Date).Format("2006-01-02")
+		if rank[habitLog.Status] > rank[source.statuses[key]] {
+			source.statuses[key] = habitLog.Status
+		}
+	}
+
+	if err := db.Where("habit_id = ? AND paused_at < ? AND (resumed_at IS NULL OR resumed_at >= ?)", habit.ID, end, from).
+		Find(&source.pauses).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Where("user_id = ? AND start_date < ? AND end_date >= ?", habit.UserID, end, from).
+		Find(&source.vacations).Error; err != nil {
+		return nil, err
+	}
+
+	var freezes []models.StreakFreeze
+	if err := db.Where("habit_id = ? AND date >= ? AND date < ?", habit.ID, from, end).
+		Find(&freezes).Error; err != nil {
+		return nil, err
+	}
+	source.frozen = make(map[string]bool, len(freezes))
+	for _, freeze := range freezes {
+		source.frozen[startOfDay(freeze.Date).Format("2006-01-02")] = true
+	}
+
+	return source.days(habit.Kind == models.HabitKindQuit, from, to, startOfDay(time.Now())), nil
+}
+
+// calendarSource is what a habit's calendar is made of: the strongest log
+// status of each day and the pauses, vacations and freezes around them
+type calendarSource struct {
+	statuses  map[string]string
+	pauses    []models.HabitPause
+	vacations []models.Vacation
+	frozen    map[string]bool
+}
+
+// days returns the state of every day from from to to. A completed log wins
+// over anything else; skipped logs, pauses, vacations and streak freezes
+// make a day neutral; other past days are missed and today is pending. For
+// a quit habit a relapse is missed even on a day off, and a past day
+// without a log is completed unless it was a day off.
+func (source *calendarSource) days(quit bool, from, to, today time.Time) []CalendarDay {
+	var days []CalendarDay
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		key := date.Format("2006-01-02")
+		day := CalendarDay{Date: key, State: stats.Missed, Status: models.LogStatusFailed}
+
+		status := source.statuses[key]
+		switch {
+		case status == models.LogStatusCompleted:
+			day.State, day.Status = stats.Done, models.LogStatusCompleted
+		case quit && status == models.LogStatusFailed:
+			// A relapse is missed at once, even today
+		case status == models.LogStatusSkipped:
+			day.State, day.Status, day.Reason = stats.Neutral, "neutral", NeutralSkipped
+		case onPause(source.pauses, date):
+			day.State, day.Status, day.Reason = stats.Neutral, "neutral", NeutralPaused
+		case onVacation(source.vacations, date):
+			day.State, day.Status, day.Reason = stats.Neutral, "neutral", NeutralVacation
+		case source.frozen[key]:
+			day.State, day.Status, day.Reason = stats.Neutral, "neutral", NeutralFrozen
+		case !date.Before(today):
+			day.State, day.Status = stats.Pending, "pending"
+		case quit:
+			// A past day without a relapse was clean
+			day.State, day.Status = stats.Done, models.LogStatusCompleted
+		}
+		days = append(days, day)
+	}
+	return days
+}
+
+// calendarCache reads the whole history of habits, up to today or the day
+// they were archived, once per habit
+type calendarCache struct {
+	db        *gorm.DB
+	today     time.Time
+	calendars map[uint][]CalendarDay
+}
+
+func newCalendarCache(db *gorm.DB, now time.Time) *calendarCache {
+	return &calendarCache{db: db, today: startOfDay(now), calendars: make(map[uint][]CalendarDay)}
+}
+
+func (cache *calendarCache) lifetime(habit *models.Habit) ([]CalendarDay, error) {
+	if days, ok := cache.calendars[habit.ID]; ok {
+		return days, nil
+	}
+	to := cache.today
+	if habit.ArchivedAt != nil {
+		to = startOfDay(*habit.ArchivedAt)
+	}
+	days, err := habitCalendar(cache.db, habit, startOfDay(habit.StartDate), to)
+	if err != nil {
+		return nil, err
+	}
+	cache.calendars[habit.ID] = days
+	return days, nil
+}
+
+func onVacation(vacations []models.Vacation, date time.Time) bool {
+	for _, vacation := range vacations {
+		if !date.Before(startOfDay(vacation.StartDate)) && !date.After(startOfDay(vacation.EndDate)) {
+			return true
+		}
+	}
+	return false
+}
+
+// onPause reports whether the habit was paused on date. The day a pause
+// starts counts as paused and the day it ends as active again.
+func onPause(pauses []models.HabitPause, date time.Time) bool {
+	for _, pause := range pauses {
+		if date.Before(startOfDay(pause.PausedAt)) {
+			continue
+		}
+		if pause.ResumedAt == nil || date.Before(startOfDay(*pause.ResumedAt)) {
+			return true
+		}
+	}
+	return false
+}
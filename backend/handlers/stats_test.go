@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"habit-tracker/models"
+	"habit-tracker/stats"
+)
+
+// testDay returns midnight of the given day in May 2024, in the server's zone
+func testDay(d int) time.Time {
+	return time.Date(2024, time.May, d, 0, 0, 0, 0, time.Local)
+}
+
+// calendarCase is one day of a calendar and what decides it
+type calendarCase struct {
+	name     string
+	quit     bool
+	date     time.Time
+	status   string
+	paused   bool
+	vacation bool
+	frozen   bool
+	want     stats.State
+	reason   string
+}
+
+func (tt calendarCase) run(t *testing.T, today time.Time) {
+	t.Helper()
+	key := tt.date.Format("2006-01-02")
+	source := calendarSource{statuses: map[string]string{}, frozen: map[string]bool{}}
+	if tt.status != "" {
+		source.statuses[key] = tt.status
+	}
+	if tt.paused {
+		resumed := tt.date.AddDate(0, 0, 1)
+		source.pauses = []models.HabitPause{{PausedAt: tt.date.Add(9 * time.Hour), ResumedAt: &resumed}}
+	}
+	if tt.vacation {
+		source.vacations = []models.Vacation{{StartDate: tt.date.AddDate(0, 0, -1), EndDate: tt.date}}
+	}
+	source.frozen[key] = tt.frozen
+
+	days := source.days(tt.quit, tt.date, tt.date, today)
+	if len(days) != 1 {
+		t.Fatalf("days() returned %d days, want 1", len(days))
+	}
+	if days[0].Date != key {
+		t.Errorf("Date = %q, want %q", days[0].Date, key)
+	}
+	if days[0].State != tt.want || days[0].Reason != tt.reason {
+		t.Errorf("day = %v %q, want %v %q", days[0].State, days[0].Reason, tt.want, tt.reason)
+	}
+}
+
+func TestCalendarDayPrecedence(t *testing.T) {
+	today := testDay(10)
+	past := testDay(8)
+
+	tests := []calendarCase{
+		{name: "completed", date: past, status: models.LogStatusCompleted, want: stats.Done},
+		{name: "no log", date: past, want: stats.Missed},
+		{name: "failed", date: past, status: models.LogStatusFailed, want: stats.Missed},
+		{name: "skipped", date: past, status: models.LogStatusSkipped, want: stats.Neutral, reason: NeutralSkipped},
+		{name: "paused", date: past, paused: true, want: stats.Neutral, reason: NeutralPaused},
+		{name: "vacation", date: past, vacation: true, want: stats.Neutral, reason: NeutralVacation},
+		{name: "frozen", date: past, frozen: true, want: stats.Neutral, reason: NeutralFrozen},
+		{name: "completed while paused", date: past, status: models.LogStatusCompleted, paused: true, want: stats.Done},
+		{name: "completed on vacation", date: past, status: models.LogStatusCompleted, vacation: true, want: stats.Done},
+		{name: "completed on a frozen day", date: past, status: models.LogStatusCompleted, frozen: true, want: stats.Done},
+		{name: "failed while paused", date: past, status: models.LogStatusFailed, paused: true, want: stats.Neutral, reason: NeutralPaused},
+		{name: "failed on vacation", date: past, status: models.LogStatusFailed, vacation: true, want: stats.Neutral, reason: NeutralVacation},
+		{name: "failed on a frozen day", date: past, status: models.LogStatusFailed, frozen: true, want: stats.Neutral, reason: NeutralFrozen},
+		{name: "paused on vacation", date: past, paused: true, vacation: true, frozen: true, want: stats.Neutral, reason: NeutralPaused},
+		{name: "vacation on a frozen day", date: past, vacation: true, frozen: true, want: stats.Neutral, reason: NeutralVacation},
+		{name: "today without a log", date: today, want: stats.Pending},
+		{name: "today failed", date: today, status: models.LogStatusFailed, want: stats.Pending},
+		{name: "today completed", date: today, status: models.LogStatusCompleted, want: stats.Done},
+		{name: "today on vacation", date: today, vacation: true, want: stats.Neutral, reason: NeutralVacation},
+
+		{name: "quit: no log", quit: true, date: past, want: stats.Done},
+		{name: "quit: relapse", quit: true, date: past, status: models.LogStatusFailed, want: stats.Missed},
+		{name: "quit: skipped", quit: true, date: past, status: models.LogStatusSkipped, want: stats.Neutral, reason: NeutralSkipped},
+		{name: "quit: paused", quit: true, date: past, paused: true, want: stats.Neutral, reason: NeutralPaused},
+		{name: "quit: vacation", quit: true, date: past, vacation: true, want: stats.Neutral, reason: NeutralVacation},
+		{name: "quit: frozen", quit: true, date: past, frozen: true, want: stats.Neutral, reason: NeutralFrozen},
+		{name: "quit: relapse while paused", quit: true, date: past, status: models.LogStatusFailed, paused: true, want: stats.Missed},
+		{name: "quit: relapse on vacation", quit: true, date: past, status: models.LogStatusFailed, vacation: true, want: stats.Missed},
+		{name: "quit: relapse on a frozen day", quit: true, date: past, status: models.LogStatusFailed, frozen: true, want: stats.Missed},
+		{name: "quit: clean while paused", quit: true, date: past, status: models.LogStatusCompleted, paused: true, want: stats.Done},
+		{name: "quit: today without a log", quit: true, date: today, want: stats.Pending},
+		{name: "quit: relapse today", quit: true, date: today, status: models.LogStatusFailed, want: stats.Missed},
+		{name: "quit: relapse today on vacation", quit: true, date: today, status: models.LogStatusFailed, vacation: true, want: stats.Missed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) { tt.run(t, today) })
+	}
+}
+
+func TestCalendarSourceDays(t *testing.T) {
+	source := calendarSource{
+		statuses: map[string]string{"2024-05-01": models.LogStatusCompleted, "2024-05-03": models.LogStatusCompleted},
+		frozen:   map[string]bool{"2024-05-02": true},
+	}
+
+	days := source.days(false, testDay(1), testDay(5), testDay(5))
+	want := []stats.State{stats.Done, stats.Neutral, stats.Done, stats.Missed, stats.Pending}
+	if len(days) != len(want) {
+		t.Fatalf("days() returned %d days, want %d", len(days), len(want))
+	}
+	for i, state := range want {
+		if days[i].State != state {
+			t.Errorf("day %s = %v, want %v", days[i].Date, days[i].State, state)
+		}
+	}
+	if summary := stats.Summarize(calendarStates(days[:3])); summary.CurrentStreak != 2 {
+		t.Errorf("streak over a frozen day = %d, want 2", summary.CurrentStreak)
+	}
+}
+
+func TestQuitCalendarDaysOff(t *testing.T) {
+	resumed := testDay(6)
+	source := calendarSource{
+		statuses:  map[string]string{"2024-05-04": models.LogStatusFailed},
+		pauses:    []models.HabitPause{{PausedAt: testDay(3), ResumedAt: &resumed}},
+		vacations: []models.Vacation{{StartDate: testDay(7), EndDate: testDay(8)}},
+		frozen:    map[string]bool{},
+	}
+
+	// Days off neither count as clean nor hide the relapse on the 4th
+	days := source.days(true, testDay(1), testDay(9), testDay(10))
+	summary := stats.Summarize(calendarStates(days))
+	want := stats.Summary{CurrentStreak: 2, LongestStreak: 2, CompletedDays: 4, MissedDays: 1, NeutralDays: 4, DueDays: 5, CompletionRate: 0.8}
+	if summary != want {
+		t.Errorf("Summarize() = %+v, want %+v", summary, want)
+	}
+}
@@ -0,0 +1,611 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"habit-tracker/apperror"
+	"habit-tracker/audit"
+	"habit-tracker/database"
+	"habit-tracker/models"
+)
+
+const (
+	SyncOpUpsert = "upsert"
+	SyncOpDelete = "delete"
+
+	SyncApplied  = "applied"
+	SyncConflict = "conflict"
+	SyncRejected = "rejected"
+)
+
+// syncOverlap is subtracted from the sync token so rows committed by a
+// transaction that started before the token was issued are not missed.
+// Clients deduplicate by id and version.
+const syncOverlap = 5 * time.Second
+
+var errInvalidSyncToken = apperror.InvalidParam("sync_token", "Invalid sync token")
+
+// SyncRequest carries the changes a client made while offline
+type SyncRequest struct {
+	// SyncToken is the token returned by the previous sync, empty for the first one
+	SyncToken string       `json:"sync_token"`
+	Changes   []SyncChange `json:"changes" binding:"max=500,dive"`
+}
+
+// SyncChange is a single client-side change. Records are identified by the
+// client-generated ClientID, or by ID once the client has learned it.
+type SyncChange struct {
+	Entity   string `json:"entity" binding:"required,oneof=habit category habit_log"`
+	Op       string `json:"op" binding:"required,oneof=upsert delete"`
+	ClientID string `json:"client_id" binding:"required,max=64"`
+	ID       uint   `json:"id"`
+	// BaseVersion is the server version the change was made on, 0 for new records
+	BaseVersion uint `json:"base_version"`
+	// UpdatedAt is when the change was made on the device
+	UpdatedAt time.Time `json:"updated_at" binding:"required"`
+	// HabitID or HabitClientID name the habit of a new habit_log
+	HabitID       uint   `json:"habit_id"`
+	HabitClientID string `json:"habit_client_id"`
+	// CategoryClientID links a habit to a category created on the device
+	CategoryClientID string `json:"category_client_id"`
+	// Data holds the changed fields, as in a merge patch of the entity
+	Data map[string]interface{} `json:"data"`
+}
+
+// SyncResult reports what happened to one change. On conflict Server holds
+// the winning server copy, or nothing when the record was deleted.
+type SyncResult struct {
+	ClientID string            `json:"client_id"`
+	Entity   string            `json:"entity"`
+	ID       uint              `json:"id,omitempty"`
+	Status   string            `json:"status"`
+	Code     string            `json:"code,omitempty"`
+	Message  string            `json:"message,omitempty"`
+	Fields   map[string]string `json:"fields,omitempty"`
+	Server   interface{}       `json:"server,omitempty"`
+
+	// files are storage keys to remove once the change has committed
+	files []string
+	// habitID is the habit whose logs the change wrote
+	habitID uint
+}
+
+// SyncResponse holds the outcome of each change and every server-side change
+// since the client's sync token
+type SyncResponse struct {
+	SyncToken  string                 `json:"sync_token"`
+	Results    []SyncResult           `json:"results"`
+	Habits     []models.Habit         `json:"habits"`
+	Categories []models.HabitCategory `json:"categories"`
+	HabitLogs  []models.HabitLog      `json:"habit_logs"`
+	Deleted    []models.Tombstone     `json:"deleted"`
+}
+
+// Sync applies a batch of offline changes and returns what changed on the
+// server since the last sync.
+//
+// Conflict policy: a change made on the current server version is applied.
+// Otherwise the most recent edit wins, comparing the device's updated_at
+// (capped at the server clock) with the server's. Deletes win over updates:
+// updating a record that was deleted on the server is a conflict.
+func Sync(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req SyncRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(c, apperror.Validation(err))
+		return
+	}
+
+	since, err := parseSyncToken(req.SyncToken)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	// Taken before applying anything so the next sync sees these changes too
+	now := time.Now()
+
+	results := make([]SyncResult, 0, len(req.Changes))
+	for _, change := range req.Changes {
+		results = append(results, applySyncChange(c, userID.(uint), change, now))
+	}
+
+	resp := SyncResponse{
+		SyncToken: encodeSyncToken(now),
+		Results:   results,
+	}
+	if err := loadSyncChanges(userID.(uint), since, &resp); err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch changes"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Sync completed successfully",
+		"data":    resp,
+	})
+}
+
+func parseSyncToken(token string) (time.Time, error) {
+	if token == "" {
+		return time.Time{}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return time.Time{}, errInvalidSyncToken
+	}
+	since, err := time.Parse(time.RFC3339Nano, string(raw))
+	if err != nil {
+		return time.Time{}, errInvalidSyncToken
+	}
+	return since.Add(-syncOverlap), nil
+}
+
+func encodeSyncToken(t time.Time) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(t.UTC().Format(time.RFC3339Nano)))
+}
+
+// loadSyncChanges fills resp with the rows changed or deleted after since
+func loadSyncChanges(userID uint, since time.Time, resp *SyncResponse) error {
+	if err := database.DB.Where("user_id = ? AND updated_at > ?", userID, since).Order("id").Find(&resp.Habits).Error; err != nil {
+		return err
+	}
+	if err := database.DB.Where("updated_at > ?", since).Order("id").Find(&resp.Categories).Error; err != nil {
+		return err
+	}
+	if err := database.DB.Where("user_id = ? AND updated_at > ?", userID, since).Order("id").Find(&resp.HabitLogs).Error; err != nil {
+		return err
+	}
+	// The first sync is a full download, so old deletions are irrelevant
+	if since.IsZero() {
+		resp.Deleted = []models.Tombstone{}
+		return nil
+	}
+	return database.DB.Where("(user_id = ? OR user_id IS NULL) AND deleted_at > ?", userID, since).Order("id").Find(&resp.Deleted).Error
+}
+
+func applySyncChange(c *gin.Context, userID uint, change SyncChange, now time.Time) SyncResult {
+	result := SyncResult{ClientID: change.ClientID, Entity: change.Entity, ID: change.ID}
+
+	// A device clock running ahead must not win every conflict
+	if change.UpdatedAt.After(now) {
+		change.UpdatedAt = now
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		switch change.Entity {
+		case audit.EntityHabit:
+			return syncHabit(tx, c, userID, change, &result)
+		case audit.EntityCategory:
+			return syncCategory(tx, c, change, &result)
+		default:
+			if err := syncHabitLog(tx, c, userID, change, &result); err != nil || result.habitID == 0 {
+				return err
+			}
+			return logsChanged(tx, []uint{result.habitID}, now)
+		}
+	})
+	if err != nil {
+		appErr := apperror.FromDB(err, nil, "Failed to apply change")
+		if appErr == errVersionMismatch {
+			// Someone else wrote the row between our read and write
+			result.Status = SyncConflict
+		} else {
+			result.Status = SyncRejected
+		}
+		result.Code = appErr.Code
+		result.Message = appErr.Message
+		result.Fields = appErr.Fields
+		result.Server = nil
+		return result
+	}
+
+	removeStoredFiles(result.files)
+	return result
+}
+
+// clientWins decides a conflict between a change and the stored record
+func clientWins(change SyncChange, version uint, updatedAt time.Time) bool {
+	return change.BaseVersion == version || change.UpdatedAt.After(updatedAt)
+}
+
+// findSyncRecord loads the record a change refers to into dest, by ID when
+// the client knows it and by client ID otherwise. It reports false when the
+// record does not exist.
+func findSyncRecord(query *gorm.DB, change SyncChange, dest interface{}) (bool, error) {
+	if change.ID != 0 {
+		query = query.Where("id = ?", change.ID)
+	} else {
+		query = query.Where("client_id = ?", change.ClientID)
+	}
+	err := query.First(dest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// wasDeleted reports whether a tombstone exists for the record of a change
+func wasDeleted(tx *gorm.DB, userID *uint, change SyncChange) (bool, error) {
+	query := tx.Model(&models.Tombstone{}).Where("entity_type = ?", change.Entity)
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+	if change.ID != 0 {
+		query = query.Where("entity_id = ?", change.ID)
+	} else {
+		query = query.Where("client_id = ?", change.ClientID)
+	}
+	var count int64
+	err := query.Count(&count).Error
+	return count > 0, err
+}
+
+func syncHabit(tx *gorm.DB, c *gin.Context, userID uint, change SyncChange, result *SyncResult) error {
+	var habit models.Habit
+	found, err := findSyncRecord(tx.Where("user_id = ?", userID), change, &habit)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		if change.Op == SyncOpDelete {
+			result.Status = SyncApplied
+			return nil
+		}
+		if deleted, err := wasDeleted(tx, &userID, change); err != nil || deleted {
+			result.Status = SyncConflict
+			result.Message = "Habit was deleted on the server"
+			return err
+		}
+		if change.ID != 0 {
+			return errHabitNotFound
+		}
+
+		clientID := change.ClientID
+		habit = models.Habit{UserID: userID, ClientID: &clientID}
+		if _, err := applyMergePatch(&habit, change.Data, HabitPatch{}); err != nil {
+			return err
+		}
+		if err := syncCategoryRef(tx, change, &habit); err != nil {
+			return err
+		}
+		applyHabitDefaults(&habit)
+		if err := validateHabit(&habit); err != nil {
+			return err
+		}
+		if err := insertHabit(tx, c, &habit); err != nil {
+			return err
+		}
+		result.Status = SyncApplied
+		result.ID = habit.ID
+		return nil
+	}
+
+	result.ID = habit.ID
+	if !clientWins(change, habit.Version, habit.UpdatedAt) {
+		result.Status = SyncConflict
+		result.Message = "Habit was changed on the server more recently"
+		result.Server = habit
+		return nil
+	}
+
+	before := habit
+	if change.Op == SyncOpDelete {
+		files, err := deleteHabitWithLogs(tx, &habit)
+		if err != nil {
+			return err
+		}
+		result.files = files
+		result.Status = SyncApplied
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionDelete,
+			EntityType: audit.EntityHabit,
+			EntityID:   habit.ID,
+			Before:     before,
+		})
+	}
+
+	columns, err := applyMergePatch(&habit, change.Data, HabitPatch{})
+	if err != nil {
+		return err
+	}
+	if change.CategoryClientID != "" {
+		if err := syncCategoryRef(tx, change, &habit); err != nil {
+			return err
+		}
+		columns = append(columns, "category_id")
+	}
+	if err := validateHabit(&habit); err != nil {
+		return err
+	}
+	if err := checkKindChange(tx, &before, &habit); err != nil {
+		return err
+	}
+	result.Status = SyncApplied
+	if len(columns) == 0 {
+		return nil
+	}
+
+	habit.Version = before.Version + 1
+	if err := versionedUpdate(tx, &before, before.Version, columns, &habit); err != nil {
+		return err
+	}
+	if err := recordPauseChange(tx, &before, &habit, time.Now()); err != nil {
+		return err
+	}
+	if err := habitsChanged(tx, []uint{habit.ID}, time.Now()); err != nil {
+		return err
+	}
+	return audit.Record(tx, c, audit.Entry{
+		Action:     audit.ActionUpdate,
+		EntityType: audit.EntityHabit,
+		EntityID:   habit.ID,
+		Before:     before,
+		After:      habit,
+	})
+}
+
+// syncCategoryRef resolves CategoryClientID to the category's server ID
+func syncCategoryRef(tx *gorm.DB, change SyncChange, habit *models.Habit) error {
+	if change.CategoryClientID == "" {
+		return nil
+	}
+	var category models.HabitCategory
+	if err := tx.Select("id").Where("client_id = ?", change.CategoryClientID).First(&category).Error; err != nil {
+		return apperror.FromDB(err, errCategoryNotFound, "Failed to fetch category")
+	}
+	habit.CategoryID = &category.ID
+	return nil
+}
+
+func syncCategory(tx *gorm.DB, c *gin.Context, change SyncChange, result *SyncResult) error {
+	var category models.HabitCategory
+	found, err := findSyncRecord(tx, change, &category)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		if change.Op == SyncOpDelete {
+			result.Status = SyncApplied
+			return nil
+		}
+		if deleted, err := wasDeleted(tx, nil, change); err != nil || deleted {
+			result.Status = SyncConflict
+			result.Message = "Category was deleted on the server"
+			return err
+		}
+		if change.ID != 0 {
+			return errCategoryNotFound
+		}
+
+		clientID := change.ClientID
+		category = models.HabitCategory{ClientID: &clientID}
+		if _, err := applyMergePatch(&category, change.Data, CategoryPatch{}); err != nil {
+			return err
+		}
+		if err := validateCategory(&category); err != nil {
+			return err
+		}
+		if err := checkCategoryParent(tx, &category); err != nil {
+			return err
+		}
+		if err := insertCategory(tx, c, &category); err != nil {
+			return err
+		}
+		result.Status = SyncApplied
+		result.ID = category.ID
+		return nil
+	}
+
+	result.ID = category.ID
+	if !clientWins(change, category.Version, category.UpdatedAt) {
+		result.Status = SyncConflict
+		result.Message = "Category was changed on the server more recently"
+		result.Server = category
+		return nil
+	}
+
+	before := category
+	if change.Op == SyncOpDelete {
+		if err := deleteCategory(tx, c, &category); err != nil {
+			return err
+		}
+		result.Status = SyncApplied
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionDelete,
+			EntityType: audit.EntityCategory,
+			EntityID:   category.ID,
+			Before:     before,
+		})
+	}
+
+	columns, err := applyMergePatch(&category, change.Data, CategoryPatch{})
+	if err != nil {
+		return err
+	}
+	if err := validateCategory(&category); err != nil {
+		return err
+	}
+	if err := checkCategoryParent(tx, &category); err != nil {
+		return err
+	}
+	result.Status = SyncApplied
+	if len(columns) == 0 {
+		return nil
+	}
+
+	category.Version = before.Version + 1
+	if err := versionedUpdate(tx, &before, before.Version, columns, &category); err != nil {
+		return err
+	}
+	return audit.Record(tx, c, audit.Entry{
+		Action:     audit.ActionUpdate,
+		EntityType: audit.EntityCategory,
+		EntityID:   category.ID,
+		Before:     before,
+		After:      category,
+	})
+}
+
+func syncHabitLog(tx *gorm.DB, c *gin.Context, userID uint, change SyncChange, result *SyncResult) error {
+	var habitLog models.HabitLog
+	found, err := findSyncRecord(tx.Where("user_id = ?", userID), change, &habitLog)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		if change.Op == SyncOpDelete {
+			result.Status = SyncApplied
+			return nil
+		}
+		if deleted, err := wasDeleted(tx, &userID, change); err != nil || deleted {
+			result.Status = SyncConflict
+			result.Message = "Habit log was deleted on the server"
+			return err
+		}
+		if change.ID != 0 {
+			return errHabitLogNotFound
+		}
+
+		// The habit may itself have been created earlier in this batch
+		habitQuery := tx.Where("user_id = ?", userID)
+		if change.HabitID != 0 {
+			habitQuery = habitQuery.Where("id = ?", change.HabitID)
+		} else {
+			habitQuery = habitQuery.Where("client_id = ?", change.HabitClientID)
+		}
+		var habit models.Habit
+		if err := habitQuery.First(&habit).Error; err != nil {
+			return apperror.FromDB(err, errHabitNotFound, "Failed to fetch habit")
+		}
+
+		clientID := change.ClientID
+		habitLog = models.HabitLog{HabitID: habit.ID, UserID: userID, ClientID: &clientID}
+		if _, err := applyMergePatch(&habitLog, change.Data, HabitLogPatch{}); err != nil {
+			return err
+		}
+		if habitLog.Date.IsZero() {
+			habitLog.Date = change.UpdatedAt
+		}
+		_, statusSet := change.Data["status"]
+		_, completedSet := change.Data["completed"]
+		if err := applyChecklist(&habit, &habitLog, !statusSet && !completedSet); err != nil {
+			return err
+		}
+		applyLogStatus(&habitLog, statusSet)
+		if err := applyHabitKind(&habit, &habitLog); err != nil {
+			return err
+		}
+		if err := validateHabitLog(&habitLog); err != nil {
+			return err
+		}
+		if err := validateLogDate("date", &habit, habitLog.Date, time.Now()); err != nil {
+			return err
+		}
+		if err := insertHabitLog(tx, c, &habitLog); err != nil {
+			return err
+		}
+		result.Status = SyncApplied
+		result.ID = habitLog.ID
+		result.habitID = habit.ID
+		return nil
+	}
+
+	result.ID = habitLog.ID
+	if !clientWins(change, habitLog.Version, habitLog.UpdatedAt) {
+		result.Status = SyncConflict
+		result.Message = "Habit log was changed on the server more recently"
+		result.Server = habitLog
+		return nil
+	}
+
+	now := time.Now()
+	if err := checkBackfillWindow("date", habitLog.Date, now); err != nil {
+		return err
+	}
+
+	before := habitLog
+	if change.Op == SyncOpDelete {
+		files, err := deleteLogAttachments(tx, []uint{habitLog.ID})
+		if err != nil {
+			return err
+		}
+		result.files = files
+		if err := tx.Where("habit_log_id = ?", habitLog.ID).Delete(&models.TimerSession{}).Error; err != nil {
+			return err
+		}
+		if err := versionedDelete(tx, &habitLog, habitLog.Version); err != nil {
+			return err
+		}
+		tombstone := newTombstone(&userID, audit.EntityHabitLog, habitLog.ID, habitLog.ClientID)
+		if err := tx.Create(&tombstone).Error; err != nil {
+			return err
+		}
+		result.Status = SyncApplied
+		result.habitID = habitLog.HabitID
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionDelete,
+			EntityType: audit.EntityHabitLog,
+			EntityID:   habitLog.ID,
+			Before:     before,
+		})
+	}
+
+	var habit models.Habit
+	if err := tx.First(&habit, habitLog.HabitID).Error; err != nil {
+		return err
+	}
+	columns, err := applyMergePatch(&habitLog, change.Data, HabitLogPatch{})
+	if err != nil {
+		return err
+	}
+	columns = applyLogAmount(&habit, &habitLog, columns)
+	if columns, err = applyLogChecklist(&habit, &habitLog, columns); err != nil {
+		return err
+	}
+	columns, statusSet := logStatusColumns(columns)
+	applyLogStatus(&habitLog, statusSet)
+	if err := validateLogChange(&habit, &before, &habitLog, now); err != nil {
+		return err
+	}
+	result.Status = SyncApplied
+	if len(columns) == 0 {
+		return nil
+	}
+	if err := checkLogDayFree(tx, &before, &habitLog); err != nil {
+		return err
+	}
+
+	habitLog.Version = before.Version + 1
+	if err := versionedUpdate(tx, &before, before.Version, columns, &habitLog); err != nil {
+		return err
+	}
+	result.habitID = habit.ID
+	return audit.Record(tx, c, audit.Entry{
+		Action:     audit.ActionUpdate,
+		EntityType: audit.EntityHabitLog,
+		EntityID:   habitLog.ID,
+		Before:     before,
+		After:      habitLog,
+	})
+}
+
+// newTombstone records the deletion of a row for sync clients
+func newTombstone(userID *uint, entityType string, id uint, clientID *string) models.Tombstone {
+	return models.Tombstone{
+		UserID:     userID,
+		EntityType: entityType,
+		EntityID:   id,
+		ClientID:   clientID,
+		DeletedAt:  time.Now(),
+	}
+}
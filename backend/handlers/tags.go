@@ -0,0 +1,507 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"habit-tracker/apperror"
+	"habit-tracker/audit"
+	"habit-tracker/database"
+	"habit-tracker/models"
+)
+
+// maxTagNameLength matches the size of Tag.Name
+const maxTagNameLength = 50
+
+// Values of the tag_match filter
+const (
+	TagMatchAny = "any"
+	TagMatchAll = "all"
+)
+
+var errTagNotFound = apperror.NotFound(apperror.CodeTagNotFound, "Tag not found")
+
+var tagSortColumns = sortableColumns{
+	"id":         kindInt,
+	"name":       kindString,
+	"created_at": kindTime,
+}
+
+// TagRequest creates or renames a tag
+type TagRequest struct {
+	Name  string `json:"name" binding:"required"`
+	Color string `json:"color"`
+}
+
+// TagMergeRequest merges a tag into another one
+type TagMergeRequest struct {
+	IntoID uint `json:"into_id" binding:"required"`
+}
+
+// HabitTagsRequest attaches tags to a habit
+type HabitTagsRequest struct {
+	TagIDs []uint `json:"tag_ids" binding:"required,min=1,max=50"`
+}
+
+// tagFilter selects habits by tag: with any of the tags, or with all of them
+type tagFilter struct {
+	IDs []uint
+	All bool
+}
+
+// parseTagFilter reads the tags and tag_match query parameters. It returns
+// nil when no tags are given.
+func parseTagFilter(c *gin.Context) (*tagFilter, error) {
+	ids, err := parseIDListQuery(c, "tags", "tags must be a comma separated list of tag IDs")
+	if err != nil || ids == nil {
+		return nil, err
+	}
+	filter := &tagFilter{IDs: uniqueIDs(ids)}
+	switch c.DefaultQuery("tag_match", TagMatchAny) {
+	case TagMatchAny:
+	case TagMatchAll:
+		filter.All = true
+	default:
+		return nil, apperror.InvalidParam("tag_match", "tag_match must be one of: any all")
+	}
+	return filter, nil
+}
+
+// apply limits a habit query to the habits matching the filter
+func (f *tagFilter) apply(query *gorm.DB) *gorm.DB {
+	if f == nil {
+		return query
+	}
+	tagged := database.DB.Model(&models.HabitTag{}).Select("habit_id").Where("tag_id IN ?", f.IDs)
+	if f.All {
+		tagged = tagged.Group("habit_id").Having("COUNT(DISTINCT tag_id) = ?", len(f.IDs))
+	}
+	return query.Where("id IN (?)", tagged)
+}
+
+func GetTags(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	params, err := parseListParams(c, tagSortColumns, "name")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	query := database.DB.Where("user_id = ?", userID)
+	if search := strings.TrimSpace(c.Query("q")); search != "" {
+		query = query.Where("name LIKE ?", "%"+escapeLike(search)+"%")
+	}
+
+	var tags []models.Tag
+	if err := params.apply(query).Find(&tags).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch tags"))
+		return
+	}
+
+	tags, page, err := paginate(params, tags)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal("Failed to build pagination cursor", err))
+		return
+	}
+
+	respondList(c, "Tags retrieved successfully", tags, page)
+}
+
+func CreateTag(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(c, apperror.Validation(err))
+		return
+	}
+
+	tag := models.Tag{UserID: userID.(uint), Name: strings.TrimSpace(req.Name), Color: req.Color}
+	if err := validateTag(database.DB, &tag); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&tag).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionCreate,
+			EntityType: audit.EntityTag,
+			EntityID:   tag.ID,
+			After:      tag,
+		})
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to create tag"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"error":   false,
+		"message": "Tag created successfully",
+		"data":    tag,
+	})
+}
+
+// UpdateTag renames or recolors a tag. Every habit carrying it changes
+// with it, so their versions are bumped in the same transaction.
+func UpdateTag(c *gin.Context) {
+	tag, err := loadTag(c, "id")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var req TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(c, apperror.Validation(err))
+		return
+	}
+
+	before := *tag
+	tag.Name = strings.TrimSpace(req.Name)
+	if req.Color != "" {
+		tag.Color = req.Color
+	}
+	if err := validateTag(database.DB, tag); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(tag).Select("name", "color").Updates(tag).Error; err != nil {
+			return err
+		}
+		if err := touchTaggedHabits(tx, tag.ID); err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionUpdate,
+			EntityType: audit.EntityTag,
+			EntityID:   tag.ID,
+			Before:     before,
+			After:      tag,
+		})
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to update tag"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Tag updated successfully",
+		"data":    tag,
+	})
+}
+
+// DeleteTag deletes a tag and removes it from every habit
+func DeleteTag(c *gin.Context) {
+	tag, err := loadTag(c, "id")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := touchTaggedHabits(tx, tag.ID); err != nil {
+			return err
+		}
+		if err := tx.Where("tag_id = ?", tag.ID).Delete(&models.HabitTag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(tag).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionDelete,
+			EntityType: audit.EntityTag,
+			EntityID:   tag.ID,
+			Before:     tag,
+		})
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to delete tag"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Tag deleted successfully",
+	})
+}
+
+// MergeTag moves every habit of a tag to the tag into_id and deletes the
+// merged tag, all in one transaction
+func MergeTag(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	source, err := loadTag(c, "id")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var req TagMergeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(c, apperror.Validation(err))
+		return
+	}
+	if req.IntoID == source.ID {
+		apperror.Abort(c, apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed").
+			WithField("into_id", "cannot merge a tag into itself"))
+		return
+	}
+
+	var target models.Tag
+	if err := database.DB.Where("id = ? AND user_id = ?", req.IntoID, userID).First(&target).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errTagNotFound, "Failed to fetch tag"))
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := touchTaggedHabits(tx, source.ID); err != nil {
+			return err
+		}
+
+		var links []models.HabitTag
+		if err := tx.Where("tag_id = ?", source.ID).Find(&links).Error; err != nil {
+			return err
+		}
+		for i := range links {
+			links[i].TagID = target.ID
+		}
+		// Habits that already carry the target keep their existing link
+		if len(links) > 0 {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("tag_id = ?", source.ID).Delete(&models.HabitTag{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Delete(source).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionDelete,
+			EntityType: audit.EntityTag,
+			EntityID:   source.ID,
+			Before:     source,
+			After:      target,
+		})
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to merge tags"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Tags merged successfully",
+		"data":    target,
+	})
+}
+
+// AttachHabitTags adds tags to a habit. Tags it already has are ignored.
+func AttachHabitTags(c *gin.Context) {
+	habit, err := loadOwnedHabit(c)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if err := checkIfMatch(c, habit.Version); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var req HabitTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(c, apperror.Validation(err))
+		return
+	}
+
+	tagIDs := uniqueIDs(req.TagIDs)
+	var count int64
+	if err := database.DB.Model(&models.Tag{}).Where("id IN ? AND user_id = ?", tagIDs, habit.UserID).Count(&count).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch tags"))
+		return
+	}
+	if int(count) != len(tagIDs) {
+		apperror.Abort(c, apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed").
+			WithField("tag_ids", "contains unknown tags"))
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var existing []uint
+		if err := tx.Model(&models.HabitTag{}).Where("habit_id = ?", habit.ID).Pluck("tag_id", &existing).Error; err != nil {
+			return err
+		}
+		attached := make(map[uint]bool, len(existing))
+		for _, id := range existing {
+			attached[id] = true
+		}
+
+		changed := false
+		for _, tagID := range tagIDs {
+			if attached[tagID] {
+				continue
+			}
+			link := models.HabitTag{HabitID: habit.ID, TagID: tagID}
+			if err := tx.Create(&link).Error; err != nil {
+				return err
+			}
+			if err := audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionCreate,
+				EntityType: audit.EntityHabitTag,
+				EntityID:   habit.ID,
+				After:      link,
+			}); err != nil {
+				return err
+			}
+			changed = true
+		}
+		if !changed {
+			return nil
+		}
+		return touchHabit(tx, &habit)
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to attach tags"))
+		return
+	}
+
+	respondTaggedHabit(c, &habit, "Tags attached successfully")
+}
+
+// DetachHabitTag removes a tag from a habit
+func DetachHabitTag(c *gin.Context) {
+	habit, err := loadOwnedHabit(c)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	tagID, err := parseIDParam(c, "tagId", "Invalid tag ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if err := checkIfMatch(c, habit.Version); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var link models.HabitTag
+	if err := database.DB.Where("habit_id = ? AND tag_id = ?", habit.ID, tagID).First(&link).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errTagNotFound, "Failed to fetch tag"))
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("habit_id = ? AND tag_id = ?", habit.ID, tagID).Delete(&models.HabitTag{}).Error; err != nil {
+			return err
+		}
+		if err := audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionDelete,
+			EntityType: audit.EntityHabitTag,
+			EntityID:   habit.ID,
+			Before:     link,
+		}); err != nil {
+			return err
+		}
+		return touchHabit(tx, &habit)
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to detach tag"))
+		return
+	}
+
+	respondTaggedHabit(c, &habit, "Tag detached successfully")
+}
+
+// loadTag loads one of the user's tags by the ID in path parameter name
+func loadTag(c *gin.Context, name string) (*models.Tag, error) {
+	userID, _ := c.Get("userID")
+	id, err := parseIDParam(c, name, "Invalid tag ID")
+	if err != nil {
+		return nil, err
+	}
+
+	var tag models.Tag
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&tag).Error; err != nil {
+		return nil, apperror.FromDB(err, errTagNotFound, "Failed to fetch tag")
+	}
+	return &tag, nil
+}
+
+// validateTag checks the name, which must be unique among the user's tags.
+// Renaming a tag to the name of another one is a merge.
+func validateTag(db *gorm.DB, tag *models.Tag) error {
+	appErr := apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed")
+	if tag.Name == "" {
+		return appErr.WithField("name", "is required")
+	}
+	if utf8.RuneCountInString(tag.Name) > maxTagNameLength {
+		return appErr.WithField("name", fmt.Sprintf("must be at most %d characters", maxTagNameLength))
+	}
+
+	var count int64
+	if err := db.Model(&models.Tag{}).Where("user_id = ? AND name = ? AND id <> ?", tag.UserID, tag.Name, tag.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return apperror.Conflict(apperror.CodeDuplicateEntry, "A tag with this name already exists; merge the tags instead")
+	}
+	return nil
+}
+
+// touchHabit bumps the version of a habit whose tags changed, so ETags
+// and offline sync pick up the change
+func touchHabit(tx *gorm.DB, habit *models.Habit) error {
+	before := *habit
+	habit.Version = before.Version + 1
+	habit.UpdatedAt = time.Now()
+	return versionedUpdate(tx, &before, before.Version, []string{"updated_at"}, habit)
+}
+
+// touchTaggedHabits bumps the version of every habit carrying the tag
+func touchTaggedHabits(tx *gorm.DB, tagID uint) error {
+	tagged := tx.Model(&models.HabitTag{}).Select("habit_id").Where("tag_id = ?", tagID)
+	return tx.Model(&models.Habit{}).Where("id IN (?)", tagged).
+		Updates(map[string]interface{}{"version": gorm.Expr("version + 1"), "updated_at": time.Now()}).Error
+}
+
+// respondTaggedHabit renders a habit after its tags changed
+func respondTaggedHabit(c *gin.Context, habit *models.Habit, message string) {
+	database.DB.Preload("Category").Preload("Tags").First(habit, habit.ID)
+
+	c.Header("ETag", versionETag(habit.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": message,
+		"data":    habit,
+	})
+}
+
+// uniqueIDs drops duplicate IDs, keeping the first occurrence
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,458 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"habit-tracker/apperror"
+	"habit-tracker/audit"
+	"habit-tracker/database"
+	"habit-tracker/models"
+)
+
+// Values of the source filter of the template list
+const (
+	TemplateSourceBuiltin = "builtin"
+	TemplateSourceMine    = "mine"
+)
+
+var errTemplateNotFound = apperror.NotFound(apperror.CodeNotFound, "Template not found")
+
+var templateSortColumns = sortableColumns{
+	"id":         kindInt,
+	"name":       kindString,
+	"created_at": kindTime,
+}
+
+// TemplateRequest creates a personal template
+type TemplateRequest struct {
+	Name               string   `json:"name" binding:"required,max=255"`
+	Description        string   `json:"description"`
+	Kind               string   `json:"kind" binding:"omitempty,oneof=build quit"`
+	Unit               string   `json:"unit" binding:"max=32"`
+	TargetPerDay       int      `json:"target_per_day" binding:"omitempty,min=1"`
+	Color              string   `json:"color"`
+	CategoryID         *uint    `json:"category_id"`
+	Schedule           string   `json:"schedule" binding:"max=64"`
+	ChecklistThreshold int      `json:"checklist_threshold" binding:"omitempty,min=1,max=100"`
+	Items              []string `json:"items" binding:"max=50,dive,max=100"`
+}
+
+// SaveTemplateRequest saves a habit as a template. Name defaults to the
+// habit's name.
+type SaveTemplateRequest struct {
+	Name     string `json:"name" binding:"max=255"`
+	Schedule string `json:"schedule" binding:"max=64"`
+}
+
+// FromTemplateRequest overrides template fields when creating a habit. The
+// body is optional.
+type FromTemplateRequest struct {
+	Name      string     `json:"name" binding:"max=255"`
+	StartDate *time.Time `json:"start_date"`
+}
+
+// ImportTemplateRequest copies a shared template into the user's templates
+type ImportTemplateRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// TemplateExport is the code others use to import a template
+type TemplateExport struct {
+	Code string `json:"code"`
+}
+
+// GetTemplates lists the built-in library and the user's own templates.
+// ?source=builtin or ?source=mine limits the list to one of them.
+func GetTemplates(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	params, err := parseListParams(c, templateSortColumns, "name")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	query := database.DB
+	switch c.Query("source") {
+	case "":
+		query = query.Where("user_id IS NULL OR user_id = ?", userID)
+	case TemplateSourceBuiltin:
+		query = query.Where("user_id IS NULL")
+	case TemplateSourceMine:
+		query = query.Where("user_id = ?", userID)
+	default:
+		apperror.Abort(c, apperror.InvalidParam("source", "source must be one of: builtin mine"))
+		return
+	}
+	if search := strings.TrimSpace(c.Query("q")); search != "" {
+		query = query.Where("name LIKE ?", "%"+escapeLike(search)+"%")
+	}
+
+	var templates []models.HabitTemplate
+	if err := params.apply(query).Preload("Category").Find(&templates).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch templates"))
+		return
+	}
+
+	templates, page, err := paginate(params, templates)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal("Failed to build pagination cursor", err))
+		return
+	}
+
+	respondList(c, "Templates retrieved successfully", templates, page)
+}
+
+func CreateTemplate(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req TemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(c, apperror.Validation(err))
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		apperror.Abort(c, apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed").WithField("name", "is required"))
+		return
+	}
+
+	owner := userID.(uint)
+	template := models.HabitTemplate{
+		UserID:             &owner,
+		Name:               req.Name,
+		Description:        req.Description,
+		Kind:               req.Kind,
+		Unit:               req.Unit,
+		TargetPerDay:       req.TargetPerDay,
+		Color:              req.Color,
+		CategoryID:         req.CategoryID,
+		Schedule:           req.Schedule,
+		ChecklistThreshold: req.ChecklistThreshold,
+		Items:              normalizeTags(req.Items),
+	}
+	applyTemplateDefaults(&template)
+
+	if err := createTemplate(c, &template); err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to create template"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"error":   false,
+		"message": "Template created successfully",
+		"data":    template,
+	})
+}
+
+func DeleteTemplate(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := parseIDParam(c, "id", "Invalid template ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	// Built-in templates have no owner, so they cannot be deleted
+	var template models.HabitTemplate
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&template).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errTemplateNotFound, "Failed to fetch template"))
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&template).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionDelete,
+			EntityType: audit.EntityTemplate,
+			EntityID:   template.ID,
+			Before:     template,
+		})
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to delete template"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Template deleted successfully",
+	})
+}
+
+// SaveHabitAsTemplate copies a habit and its checklist into a new personal
+// template
+func SaveHabitAsTemplate(c *gin.Context) {
+	habit, err := loadOwnedHabit(c)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var req SaveTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		apperror.Abort(c, apperror.Validation(err))
+		return
+	}
+
+	items, err := habitItems(database.DB, habit.ID)
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch checklist items"))
+		return
+	}
+
+	template := models.HabitTemplate{
+		UserID:             &habit.UserID,
+		Name:               habit.Name,
+		Description:        habit.Description,
+		Kind:               habit.Kind,
+		Unit:               habit.Unit,
+		TargetPerDay:       habit.TargetPerDay,
+		Color:              habit.Color,
+		CategoryID:         habit.CategoryID,
+		Schedule:           req.Schedule,
+		ChecklistThreshold: habit.ChecklistThreshold,
+		Items:              models.StringList{},
+	}
+	if name := strings.TrimSpace(req.Name); name != "" {
+		template.Name = name
+	}
+	for _, item := range items {
+		template.Items = append(template.Items, item.Name)
+	}
+	applyTemplateDefaults(&template)
+
+	if err := createTemplate(c, &template); err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to create template"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"error":   false,
+		"message": "Template created successfully",
+		"data":    template,
+	})
+}
+
+// CreateHabitFromTemplate creates a habit, with its checklist, from a
+// built-in or personal template
+func CreateHabitFromTemplate(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := parseIDParam(c, "id", "Invalid template ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var req FromTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		apperror.Abort(c, apperror.Validation(err))
+		return
+	}
+
+	var template models.HabitTemplate
+	if err := database.DB.Where("id = ? AND (user_id IS NULL OR user_id = ?)", id, userID).First(&template).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errTemplateNotFound, "Failed to fetch template"))
+		return
+	}
+
+	habit := models.Habit{
+		UserID:             userID.(uint),
+		CategoryID:         template.CategoryID,
+		Name:               template.Name,
+		Description:        template.Description,
+		Kind:               template.Kind,
+		Unit:               template.Unit,
+		ChecklistThreshold: template.ChecklistThreshold,
+		Color:              template.Color,
+		IsActive:           true,
+		TargetPerDay:       template.TargetPerDay,
+	}
+	if name := strings.TrimSpace(req.Name); name != "" {
+		habit.Name = name
+	}
+	if req.StartDate != nil {
+		habit.StartDate = *req.StartDate
+	}
+	applyHabitDefaults(&habit)
+	if err := validateHabit(&habit); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := insertHabit(tx, c, &habit); err != nil {
+			return err
+		}
+
+		for position, name := range template.Items {
+			item := models.HabitItem{HabitID: habit.ID, UserID: habit.UserID, Name: name, Position: position}
+			if err := tx.Create(&item).Error; err != nil {
+				return err
+			}
+			if err := audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionCreate,
+				EntityType: audit.EntityHabitItem,
+				EntityID:   item.ID,
+				After:      item,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to create habit"))
+		return
+	}
+
+	// Load the category relationship
+	database.DB.Preload("Category").First(&habit, habit.ID)
+
+	c.Header("ETag", versionETag(habit.Version))
+	c.JSON(http.StatusCreated, gin.H{
+		"error":   false,
+		"message": "Habit created successfully",
+		"data":    habit,
+	})
+}
+
+// ExportTemplate returns the share code of one of the user's templates,
+// creating it on first use
+func ExportTemplate(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := parseIDParam(c, "id", "Invalid template ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var template models.HabitTemplate
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&template).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errTemplateNotFound, "Failed to fetch template"))
+		return
+	}
+
+	if template.ShareCode == nil {
+		code, err := shareCode()
+		if err != nil {
+			apperror.Abort(c, apperror.Internal("Failed to generate share code", err))
+			return
+		}
+
+		before := template
+		template.ShareCode = &code
+		err = database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&template).Update("share_code", code).Error; err != nil {
+				return err
+			}
+			return audit.Record(tx, c, audit.Entry{
+				Action:     audit.ActionUpdate,
+				EntityType: audit.EntityTemplate,
+				EntityID:   template.ID,
+				Before:     before,
+				After:      template,
+			})
+		})
+		if err != nil {
+			apperror.Abort(c, apperror.FromDB(err, nil, "Failed to export template"))
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Template exported successfully",
+		"data":    TemplateExport{Code: *template.ShareCode},
+	})
+}
+
+// ImportTemplate copies the template behind a share code into the user's
+// templates. Later changes to the original are not picked up.
+func ImportTemplate(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req ImportTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(c, apperror.Validation(err))
+		return
+	}
+
+	var shared models.HabitTemplate
+	code := strings.ToUpper(strings.TrimSpace(req.Code))
+	if err := database.DB.Where("share_code = ?", code).First(&shared).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errTemplateNotFound, "Failed to fetch template"))
+		return
+	}
+
+	owner := userID.(uint)
+	template := shared
+	template.ID = 0
+	template.UserID = &owner
+	template.ShareCode = nil
+	template.CreatedAt, template.UpdatedAt = time.Time{}, time.Time{}
+
+	if err := createTemplate(c, &template); err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to import template"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"error":   false,
+		"message": "Template imported successfully",
+		"data":    template,
+	})
+}
+
+func createTemplate(c *gin.Context, template *models.HabitTemplate) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(template).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionCreate,
+			EntityType: audit.EntityTemplate,
+			EntityID:   template.ID,
+			After:      template,
+		})
+	})
+}
+
+// applyTemplateDefaults fills the fields a template may omit
+func applyTemplateDefaults(template *models.HabitTemplate) {
+	if template.Kind == "" {
+		template.Kind = models.HabitKindBuild
+	}
+	if template.TargetPerDay == 0 {
+		template.TargetPerDay = 1
+	}
+	if template.ChecklistThreshold == 0 {
+		template.ChecklistThreshold = 100
+	}
+	if template.Color == "" {
+		template.Color = "#6366f1"
+	}
+	if template.Items == nil {
+		template.Items = models.StringList{}
+	}
+}
+
+// shareCode returns a random, easy to type code for sharing a template
+func shareCode() (string, error) {
+	raw := make([]byte, 5)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("read random bytes: %w", err)
+	}
+	return base32.StdEncoding.EncodeToString(raw), nil
+}
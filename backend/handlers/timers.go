@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"habit-tracker/apperror"
+	"habit-tracker/audit"
+	"habit-tracker/database"
+	"habit-tracker/models"
+)
+
+// defaultTimerMaxHours is used when TIMER_MAX_HOURS is unset or invalid
+const defaultTimerMaxHours = 12
+
+// TimerStopResponse lists the sessions a stopped timer was split into, one
+// per day, and the logs they were added to; sessions shorter than a minute
+// may have no log yet. Capped is set when the timer ran longer than the
+// maximum and was cut off.
+type TimerStopResponse struct {
+	Sessions []models.TimerSession `json:"sessions"`
+	Logs     []models.HabitLog     `json:"logs"`
+	Capped   bool                  `json:"capped"`
+}
+
+// timerMaxDuration is the longest a timer may run, read from TIMER_MAX_HOURS.
+// A forgotten timer is stopped at this length.
+func timerMaxDuration() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("TIMER_MAX_HOURS"))
+	if err != nil || hours < 1 {
+		return defaultTimerMaxHours * time.Hour
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// GetActiveTimers lists the user's running timers. Duration is the time
+// elapsed so far.
+func GetActiveTimers(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var sessions []models.TimerSession
+	if err := database.DB.Where("user_id = ? AND stopped_at IS NULL", userID).
+		Preload("Habit").Order("started_at").Find(&sessions).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch timers"))
+		return
+	}
+
+	now := time.Now()
+	for i := range sessions {
+		sessions[i].Duration = int(now.Sub(sessions[i].StartedAt).Seconds())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Active timers retrieved successfully",
+		"data":    sessions,
+	})
+}
+
+func StartTimer(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	habitID, err := parseIDParam(c, "id", "Invalid habit ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var habit models.Habit
+	if err := database.DB.Where("id = ? AND user_id = ?", habitID, userID).First(&habit).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errHabitNotFound, "Failed to fetch habit"))
+		return
+	}
+	if err := checkTimeable(&habit); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	now := time.Now()
+	if err := validateLogDate("started_at", &habit, now, now); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	session := models.TimerSession{UserID: habit.UserID, HabitID: habit.ID, StartedAt: now}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the habit so concurrent requests cannot start two timers
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Habit{}, habit.ID).Error; err != nil {
+			return err
+		}
+
+		var running int64
+		if err := tx.Model(&models.TimerSession{}).
+			Where("habit_id = ? AND stopped_at IS NULL", habit.ID).
+			Count(&running).Error; err != nil {
+			return err
+		}
+		if running > 0 {
+			return apperror.Conflict(apperror.CodeTimerRunning, "A timer is already running for this habit")
+		}
+
+		if err := tx.Omit(clause.Associations).Create(&session).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionCreate,
+			EntityType: audit.EntityTimerSession,
+			EntityID:   session.ID,
+			After:      session,
+		})
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to start timer"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"error":   false,
+		"message": "Timer started successfully",
+		"data":    session,
+	})
+}
+
+// StopTimer stops the habit's running timer and adds its minutes to the
+// log of the day. A timer that ran past midnight is split at each midnight
+// and every part counts toward its own day.
+func StopTimer(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	habitID, err := parseIDParam(c, "id", "Invalid habit ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var habit models.Habit
+	if err := database.DB.Where("id = ? AND user_id = ?", habitID, userID).First(&habit).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errHabitNotFound, "Failed to fetch habit"))
+		return
+	}
+
+	resp := TimerStopResponse{Logs: []models.HabitLog{}}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var session models.TimerSession
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("habit_id = ? AND stopped_at IS NULL", habit.ID).First(&session).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.Conflict(apperror.CodeTimerNotRunning, "No timer is running for this habit")
+		}
+		if err != nil {
+			return err
+		}
+
+		end := time.Now()
+		if maxDuration := timerMaxDuration(); end.Sub(session.StartedAt) > maxDuration {
+			end = session.StartedAt.Add(maxDuration)
+			resp.Capped = true
+		}
+
+		before := session
+		for start, first := session.StartedAt, true; first || start.Before(end); first = false {
+			stop := startOfDay(start).AddDate(0, 0, 1)
+			if end.Before(stop) {
+				stop = end
+			}
+
+			part := models.TimerSession{UserID: session.UserID, HabitID: session.HabitID, StartedAt: start}
+			if first {
+				part = session
+			}
+			part.StoppedAt = &stop
+			part.Duration = int(stop.Sub(start).Seconds())
+
+			habitLog, err := addTimedSeconds(tx, c, &habit, start, part.Duration)
+			if err != nil {
+				return err
+			}
+			if habitLog != nil {
+				part.HabitLogID = &habitLog.ID
+				resp.Logs = append(resp.Logs, *habitLog)
+			}
+
+			if first {
+				err = tx.Model(&part).Select("habit_log_id", "stopped_at", "duration").Updates(&part).Error
+			} else {
+				err = tx.Omit(clause.Associations).Create(&part).Error
+			}
+			if err != nil {
+				return err
+			}
+			entry := audit.Entry{Action: audit.ActionCreate, EntityType: audit.EntityTimerSession, EntityID: part.ID, After: part}
+			if first {
+				entry.Action, entry.Before = audit.ActionUpdate, before
+			}
+			if err := audit.Record(tx, c, entry); err != nil {
+				return err
+			}
+
+			resp.Sessions = append(resp.Sessions, part)
+			start = stop
+		}
+		return logsChanged(tx, []uint{habit.ID}, time.Now())
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to stop timer"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Timer stopped successfully",
+		"data":    resp,
+	})
+}
+
+// checkTimeable rejects timers on habits that are not measured in minutes
+// or are not currently tracked
+func checkTimeable(habit *models.Habit) error {
+	if habit.ArchivedAt != nil {
+		return apperror.Conflict(apperror.CodeHabitArchived, "Habit is archived")
+	}
+	if !habit.IsActive {
+		return apperror.Conflict(apperror.CodeHabitPaused, "Habit is paused")
+	}
+	if habit.Kind == models.HabitKindQuit || habit.Unit != models.UnitMinutes {
+		return apperror.Conflict(apperror.CodeHabitNotTimed, "Only habits measured in minutes can be timed")
+	}
+	return nil
+}
+
+// addTimedSeconds adds a timed session of the given length to the log of
+// the day at falls on. The log's amount counts whole minutes of the day's
+// sessions, so short sessions add up; earlier manual changes to the amount
+// are kept. No log is created until the sessions reach a minute, in which
+// case the returned log is nil.
+func addTimedSeconds(tx *gorm.DB, c *gin.Context, habit *models.Habit, at time.Time, seconds int) (*models.HabitLog, error) {
+	day := startOfDay(at)
+	next := day.AddDate(0, 0, 1)
+
+	// Sessions never cross midnight, so a day's sessions start on that day
+	var timed int
+	if err := tx.Model(&models.TimerSession{}).Select("COALESCE(SUM(duration), 0)").
+		Where("habit_id = ? AND stopped_at IS NOT NULL AND started_at >= ? AND started_at < ?", habit.ID, day, next).
+		Scan(&timed).Error; err != nil {
+		return nil, err
+	}
+	minutes := (timed+seconds)/60 - timed/60
+
+	var habitLog models.HabitLog
+	err := tx.Where("habit_id = ? AND date >= ? AND date < ?", habit.ID, day, next).Order("id").First(&habitLog).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if minutes == 0 {
+			return nil, nil
+		}
+		habitLog = models.HabitLog{HabitID: habit.ID, UserID: habit.UserID, Date: at, Amount: minutes}
+		habitLog.Completed = completedByAmount(habit, habitLog.Amount)
+		applyLogStatus(&habitLog, false)
+		return &habitLog, insertHabitLog(tx, c, &habitLog)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if minutes == 0 {
+		return &habitLog, nil
+	}
+
+	before := habitLog
+	habitLog.Amount += minutes
+	if completedByAmount(habit, habitLog.Amount) {
+		habitLog.Completed = true
+	}
+	applyLogStatus(&habitLog, false)
+	habitLog.Version = before.Version + 1
+	if err := versionedUpdate(tx, &before, before.Version, []string{"amount", "completed", "status", "skip_reason"}, &habitLog); err != nil {
+		return nil, err
+	}
+	return &habitLog, audit.Record(tx, c, audit.Entry{
+		Action:     audit.ActionUpdate,
+		EntityType: audit.EntityHabitLog,
+		EntityID:   habitLog.ID,
+		Before:     before,
+		After:      habitLog,
+	})
+}
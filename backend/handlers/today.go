@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"habit-tracker/apperror"
+	"habit-tracker/database"
+	"habit-tracker/models"
+)
+
+// TodaySection groups today's habits done at one time of day
+type TodaySection struct {
+	TimeOfDay string       `json:"time_of_day"`
+	Habits    []TodayHabit `json:"habits"`
+}
+
+// TodayHabit is a habit due today with today's log, nil when not logged yet
+type TodayHabit struct {
+	models.Habit
+	Log *models.HabitLog `json:"log"`
+}
+
+// GetTodayHabits lists the habits due today in time-of-day sections:
+// morning, afternoon, evening, then anytime. Every section is present, and
+// habits keep the user's order within it. Paused, archived and not yet
+// started habits are left out.
+func GetTodayHabits(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	today := startOfDay(time.Now())
+	tomorrow := today.AddDate(0, 0, 1)
+
+	var habits []models.Habit
+	if err := database.DB.Where("user_id = ? AND is_active = ? AND archived_at IS NULL AND start_date < ?", userID, true, tomorrow).
+		Preload("Category").Preload("Tags").Order("position").Order("id").Find(&habits).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch habits"))
+		return
+	}
+
+	var habitLogs []models.HabitLog
+	if err := database.DB.Where("user_id = ? AND date >= ? AND date < ?", userID, today, tomorrow).
+		Order("id").Find(&habitLogs).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch habit logs"))
+		return
+	}
+	// A habit has one log per day; days logged twice before that was
+	// enforced show the first
+	logs := make(map[uint]*models.HabitLog, len(habitLogs))
+	for i := range habitLogs {
+		if logs[habitLogs[i].HabitID] == nil {
+			logs[habitLogs[i].HabitID] = &habitLogs[i]
+		}
+	}
+
+	sections := make([]TodaySection, len(models.TimesOfDay))
+	index := make(map[string]int, len(models.TimesOfDay))
+	for i, timeOfDay := range models.TimesOfDay {
+		sections[i] = TodaySection{TimeOfDay: timeOfDay, Habits: []TodayHabit{}}
+		index[timeOfDay] = i
+	}
+	for _, habit := range habits {
+		i, ok := index[habit.TimeOfDay]
+		if !ok {
+			i = index[models.TimeOfDayAnytime]
+		}
+		sections[i].Habits = append(sections[i].Habits, TodayHabit{Habit: habit, Log: logs[habit.ID]})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Today's habits retrieved successfully",
+		"data":    sections,
+	})
+}
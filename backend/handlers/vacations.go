@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"habit-tracker/apperror"
+	"habit-tracker/audit"
+	"habit-tracker/database"
+	"habit-tracker/models"
+)
+
+// maxVacationDays limits the length of a single vacation
+const maxVacationDays = 366
+
+var errVacationNotFound = apperror.NotFound(apperror.CodeNotFound, "Vacation not found")
+
+var vacationSortColumns = sortableColumns{
+	"id":         kindInt,
+	"start_date": kindTime,
+	"end_date":   kindTime,
+	"created_at": kindTime,
+}
+
+// VacationRequest creates a vacation from StartDate to EndDate, inclusive
+type VacationRequest struct {
+	StartDate string `json:"start_date" binding:"required,datetime=2006-01-02"`
+	EndDate   string `json:"end_date" binding:"required,datetime=2006-01-02"`
+	Reason    string `json:"reason" binding:"max=255"`
+}
+
+func GetVacations(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	params, err := parseListParams(c, vacationSortColumns, "-start_date")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var vacations []models.Vacation
+	if err := params.apply(database.DB.Where("user_id = ?", userID)).Find(&vacations).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch vacations"))
+		return
+	}
+
+	vacations, page, err := paginate(params, vacations)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal("Failed to build pagination cursor", err))
+		return
+	}
+
+	respondList(c, "Vacations retrieved successfully", vacations, page)
+}
+
+func CreateVacation(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req VacationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(c, apperror.Validation(err))
+		return
+	}
+
+	// Both dates passed the binding's format check
+	startDate, _ := time.ParseInLocation("2006-01-02", req.StartDate, time.Local)
+	endDate, _ := time.ParseInLocation("2006-01-02", req.EndDate, time.Local)
+	if endDate.Before(startDate) {
+		apperror.Abort(c, apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed").WithField("end_date", "must not be before start_date"))
+		return
+	}
+	if endDate.Sub(startDate) >= maxVacationDays*24*time.Hour {
+		apperror.Abort(c, apperror.BadRequest(apperror.CodeValidationFailed, "Request validation failed").WithField("end_date", fmt.Sprintf("vacation must be at most %d days", maxVacationDays)))
+		return
+	}
+
+	vacation := models.Vacation{
+		UserID:    userID.(uint),
+		StartDate: startDate,
+		EndDate:   endDate,
+		Reason:    req.Reason,
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var overlapping int64
+		if err := tx.Model(&models.Vacation{}).
+			Where("user_id = ? AND start_date <= ? AND end_date >= ?", vacation.UserID, endDate, startDate).
+			Count(&overlapping).Error; err != nil {
+			return err
+		}
+		if overlapping > 0 {
+			return apperror.Conflict(apperror.CodeVacationOverlap, "Vacation overlaps an existing vacation")
+		}
+
+		if err := tx.Create(&vacation).Error; err != nil {
+			return err
+		}
+		if err := userHabitsChanged(tx, vacation.UserID, time.Now()); err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionCreate,
+			EntityType: audit.EntityVacation,
+			EntityID:   vacation.ID,
+			After:      vacation,
+		})
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to create vacation"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"error":   false,
+		"message": "Vacation created successfully",
+		"data":    vacation,
+	})
+}
+
+func DeleteVacation(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := parseIDParam(c, "id", "Invalid vacation ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	var vacation models.Vacation
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&vacation).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, errVacationNotFound, "Failed to fetch vacation"))
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&vacation).Error; err != nil {
+			return err
+		}
+		if err := userHabitsChanged(tx, vacation.UserID, time.Now()); err != nil {
+			return err
+		}
+		return audit.Record(tx, c, audit.Entry{
+			Action:     audit.ActionDelete,
+			EntityType: audit.EntityVacation,
+			EntityID:   vacation.ID,
+			Before:     vacation,
+		})
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to delete vacation"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Vacation deleted successfully",
+	})
+}
@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"math"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"habit-tracker/apperror"
+	"habit-tracker/database"
+	"habit-tracker/models"
+	"habit-tracker/stats"
+)
+
+// baseLogXP is the XP of a completed log of a medium habit without a streak
+const baseLogXP = 10
+
+// difficultyXP weighs the XP of a log by its habit's difficulty
+var difficultyXP = map[string]float64{
+	models.DifficultyEasy:   0.5,
+	models.DifficultyMedium: 1,
+	models.DifficultyHard:   2,
+}
+
+// levelThresholds is the total XP each level starts at, from level 1.
+// Past the last one every level takes levelStepXP more.
+var levelThresholds = []int{0, 100, 250, 500, 1000, 1750, 2750, 4000, 5500, 7500, 10000}
+
+const levelStepXP = 3000
+
+var xpEventSortColumns = sortableColumns{
+	"id":         kindInt,
+	"created_at": kindTime,
+}
+
+// LevelProgress is the level a user's XP reaches and how far it is to the
+// next one. Progress runs from 0 to 1.
+type LevelProgress struct {
+	Level       int     `json:"level"`
+	LevelXP     int     `json:"level_xp"`
+	NextLevelXP int     `json:"next_level_xp"`
+	Progress    float64 `json:"progress"`
+}
+
+// loggedXP is what a log earned so far, or is worth now
+type loggedXP struct {
+	HabitLogID uint
+	Amount     int
+	Streak     int
+}
+
+// GetXPEvents lists the user's XP history, newest first
+func GetXPEvents(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	params, err := parseListParams(c, xpEventSortColumns, "-id")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	query := database.DB.Where("user_id = ?", userID)
+	habitID, err := parseIDQuery(c, "habit_id", "Invalid habit ID")
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if habitID != nil {
+		query = query.Where("habit_id = ?", *habitID)
+	}
+
+	var xpEvents []models.XPEvent
+	if err := params.apply(query).Find(&xpEvents).Error; err != nil {
+		apperror.Abort(c, apperror.FromDB(err, nil, "Failed to fetch XP events"))
+		return
+	}
+
+	xpEvents, page, err := paginate(params, xpEvents)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal("Failed to build pagination cursor", err))
+		return
+	}
+
+	respondList(c, "XP events retrieved successfully", xpEvents, page)
+}
+
+// RecomputeXP rebuilds the XP of one user, or of every user when userID is
+// nil, from the full log history. Logs whose XP drifted get a correction
+// event and the stored totals are summed again from the events.
+func RecomputeXP(userID *uint, now time.Time) error {
+	var userIDs []uint
+	query := database.DB.Model(&models.User{}).Order("id")
+	if userID != nil {
+		query = query.Where("id = ?", *userID)
+	}
+	if err := query.Pluck("id", &userIDs).Error; err != nil {
+		return err
+	}
+
+	for _, id := range userIDs {
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			return recomputeUserXP(tx, id, now)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func recomputeUserXP(tx *gorm.DB, userID uint, now time.Time) error {
+	var habits []models.Habit
+	if err := tx.Where("user_id = ?", userID).Order("id").Find(&habits).Error; err != nil {
+		return err
+	}
+
+	calendars := newCalendarCache(tx, now)
+	for i := range habits {
+		if err := reconcileHabitXP(tx, userID, habits[i].ID, &habits[i], calendars, models.XPReasonRecompute, now); err != nil {
+			return err
+		}
+	}
+
+	// XP left behind by habits that no longer exist
+	var orphaned []uint
+	if err := tx.Model(&models.XPEvent{}).Distinct("habit_id").
+		Where("user_id = ? AND habit_id NOT IN (?)", userID, tx.Model(&models.Habit{}).Select("id").Where("user_id = ?", userID)).
+		Pluck("habit_id", &orphaned).Error; err != nil {
+		return err
+	}
+	for _, habitID := range orphaned {
+		if err := reconcileHabitXP(tx, userID, habitID, nil, calendars, models.XPReasonRecompute, now); err != nil {
+			return err
+		}
+	}
+
+	total := tx.Model(&models.XPEvent{}).Select("COALESCE(SUM(amount), 0)").Where("user_id = ?", userID)
+	return tx.Model(&models.User{}).Where("id = ?", userID).UpdateColumn("xp", total).Error
+}
+
+// reconcileHabitXP brings the XP a habit's logs earned in line with what
+// they are worth now. A completed log that never earned XP gets its own
+// event; whatever else changed, such as the streak bonus of later logs,
+// becomes one correction for the habit. habit is nil once the habit has
+// been deleted, which takes all its XP back. reason overrides the reasons
+// derived from the change when set. Quit habits have no completed logs and
+// earn no XP.
+func reconcileHabitXP(tx *gorm.DB, userID, habitID uint, habit *models.Habit, calendars *calendarCache, reason string, now time.Time) error {
+	// Lock the user so concurrent writes cannot both record a correction
+	// for the same change
+	if err := lockUser(tx, userID); err != nil {
+		return err
+	}
+
+	var habitLogs []models.HabitLog
+	if habit != nil {
+		if err := tx.Select("id", "date", "status").Where("habit_id = ?", habitID).Order("id").Find(&habitLogs).Error; err != nil {
+			return err
+		}
+	}
+	exists := make(map[uint]bool, len(habitLogs))
+	var worth []loggedXP
+	for _, habitLog := range habitLogs {
+		exists[habitLog.ID] = true
+		if habitLog.Status == models.LogStatusCompleted {
+			worth = append(worth, loggedXP{HabitLogID: habitLog.ID})
+		}
+	}
+	if len(worth) > 0 {
+		days, err := calendars.lifetime(habit)
+		if err != nil {
+			return err
+		}
+		streaks := dailyStreaks(days)
+		dates := make(map[uint]string, len(habitLogs))
+		for _, habitLog := range habitLogs {
+			dates[habitLog.ID] = startOfDay(habitLog.Date).Format("2006-01-02")
+		}
+		for i := range worth {
+			worth[i].Streak = streaks[dates[worth[i].HabitLogID]]
+			worth[i].Amount = logXP(habit.Difficulty, worth[i].Streak)
+		}
+	}
+
+	// Corrections are not tied to a log, so only the total per log tells
+	// which logs earned XP before
+	var earned []loggedXP
+	if err := tx.Model(&models.XPEvent{}).Select("habit_log_id, SUM(amount) AS amount").
+		Where("user_id = ? AND habit_id = ?", userID, habitID).Group("habit_log_id").
+		Scan(&earned).Error; err != nil {
+		return err
+	}
+	newLogs, correction, deleted := xpChanges(worth, earned, exists)
+
+	var xpEvents []models.XPEvent
+	for _, value := range newLogs {
+		xpEvents = append(xpEvents, models.XPEvent{UserID: userID, HabitID: habitID, HabitLogID: value.HabitLogID, Amount: value.Amount, Reason: models.XPReasonLogCompleted, Streak: value.Streak, CreatedAt: now})
+	}
+	if correction != 0 {
+		xpEvent := models.XPEvent{UserID: userID, HabitID: habitID, Amount: correction, Reason: models.XPReasonLogChanged, CreatedAt: now}
+		if deleted {
+			xpEvent.Reason = models.XPReasonLogDeleted
+		}
+		xpEvents = append(xpEvents, xpEvent)
+	}
+	if len(xpEvents) == 0 {
+		return nil
+	}
+
+	total := 0
+	for i := range xpEvents {
+		if reason != "" {
+			xpEvents[i].Reason = reason
+		}
+		total += xpEvents[i].Amount
+	}
+	if err := tx.Create(&xpEvents).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.User{}).Where("id = ?", userID).UpdateColumn("xp", gorm.Expr("xp + ?", total)).Error
+}
+
+// xpChanges compares what a habit's completed logs are worth with what its
+// logs earned so far. It returns the logs that never earned XP, and the
+// net correction for all the others, which also takes back the XP of logs
+// no longer completed. deleted reports whether one of those logs is gone.
+func xpChanges(worth, earned []loggedXP, exists map[uint]bool) (newLogs []loggedXP, correction int, deleted bool) {
+	earnedBy := make(map[uint]bool, len(earned))
+	for _, item := range earned {
+		earnedBy[item.HabitLogID] = true
+		correction -= item.Amount
+		if item.HabitLogID != 0 && !exists[item.HabitLogID] && item.Amount != 0 {
+			deleted = true
+		}
+	}
+	for _, value := range worth {
+		if earnedBy[value.HabitLogID] {
+			correction += value.Amount
+		} else {
+			newLogs = append(newLogs, value)
+		}
+	}
+	return newLogs, correction, deleted
+}
+
+// lockUser locks the user's row for the rest of the transaction. The XP of
+// a user is only changed while holding it.
+func lockUser(tx *gorm.DB, userID uint) error {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.User{}, userID).Error
+}
+
+// dailyStreaks maps each day of a habit's history to the streak it is part
+// of, counting that day
+func dailyStreaks(days []CalendarDay) map[string]int {
+	streaks := make(map[string]int, len(days))
+	streak := 0
+	for _, day := range days {
+		switch day.State {
+		case stats.Done:
+			streak++
+		case stats.Missed:
+			streak = 0
+		}
+		streaks[day.Date] = streak
+	}
+	return streaks
+}
+
+// logXP is the XP of a completed log: baseLogXP weighed by the habit's
+// difficulty, plus 10% for every full week of the streak it extends, up to
+// twice as much
+func logXP(difficulty string, streak int) int {
+	weight, ok := difficultyXP[difficulty]
+	if !ok {
+		weight = difficultyXP[models.DifficultyMedium]
+	}
+	bonus := math.Min(float64(streak/7)*0.1, 1)
+	return int(math.Round(baseLogXP * weight * (1 + bonus)))
+}
+
+// levelFor returns the level a total XP reaches
+func levelFor(xp int) LevelProgress {
+	level, start, next := 1, 0, levelThresholds[1]
+	for level < len(levelThresholds) && xp >= levelThresholds[level] {
+		level++
+	}
+	if level < len(levelThresholds) {
+		start, next = levelThresholds[level-1], levelThresholds[level]
+	} else {
+		last := levelThresholds[len(levelThresholds)-1]
+		extra := 0
+		if xp > last {
+			extra = (xp - last) / levelStepXP
+		}
+		level += extra
+		start = last + extra*levelStepXP
+		next = start + levelStepXP
+	}
+
+	progress := 0.0
+	if xp > start {
+		progress = float64(xp-start) / float64(next-start)
+	}
+	return LevelProgress{Level: level, LevelXP: start, NextLevelXP: next, Progress: math.Round(progress*1000) / 1000}
+}
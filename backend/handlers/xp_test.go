@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"fmt"
+	"testing"
+
+	"habit-tracker/models"
+)
+
+func TestLogXP(t *testing.T) {
+	tests := []struct {
+		name       string
+		difficulty string
+		streak     int
+		want       int
+	}{
+		{"easy", models.DifficultyEasy, 0, 5},
+		{"medium", models.DifficultyMedium, 0, 10},
+		{"hard", models.DifficultyHard, 0, 20},
+		{"unknown difficulty counts as medium", "epic", 0, 10},
+		{"no bonus before a full week", models.DifficultyMedium, 6, 10},
+		{"one week", models.DifficultyMedium, 7, 11},
+		{"one week rounds half up", models.DifficultyEasy, 7, 6},
+		{"three weeks", models.DifficultyMedium, 21, 13},
+		{"bonus reaches the cap", models.DifficultyMedium, 70, 20},
+		{"bonus stays at the cap", models.DifficultyMedium, 365, 20},
+		{"hard at the cap", models.DifficultyHard, 70, 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := logXP(tt.difficulty, tt.streak); got != tt.want {
+				t.Errorf("logXP(%q, %d) = %d, want %d", tt.difficulty, tt.streak, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLevelFor(t *testing.T) {
+	tests := []struct {
+		name string
+		xp   int
+		want LevelProgress
+	}{
+		{"no XP", 0, LevelProgress{Level: 1, LevelXP: 0, NextLevelXP: 100, Progress: 0}},
+		{"just below level 2", 99, LevelProgress{Level: 1, LevelXP: 0, NextLevelXP: 100, Progress: 0.99}},
+		{"level 2", 100, LevelProgress{Level: 2, LevelXP: 100, NextLevelXP: 250, Progress: 0}},
+		{"halfway through level 3", 375, LevelProgress{Level: 3, LevelXP: 250, NextLevelXP: 500, Progress: 0.5}},
+		{"progress rounds to three decimals", 1250, LevelProgress{Level: 5, LevelXP: 1000, NextLevelXP: 1750, Progress: 0.333}},
+		{"last listed level", 9000, LevelProgress{Level: 10, LevelXP: 7500, NextLevelXP: 10000, Progress: 0.6}},
+		{"last threshold", 10000, LevelProgress{Level: 11, LevelXP: 10000, NextLevelXP: 13000, Progress: 0}},
+		{"one step past the last threshold", 13000, LevelProgress{Level: 12, LevelXP: 13000, NextLevelXP: 16000, Progress: 0}},
+		{"within a step", 17500, LevelProgress{Level: 13, LevelXP: 16000, NextLevelXP: 19000, Progress: 0.5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := levelFor(tt.xp); got != tt.want {
+				t.Errorf("levelFor(%d) = %+v, want %+v", tt.xp, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDailyStreaks(t *testing.T) {
+	tests := []struct {
+		name   string
+		states string
+		want   []int
+	}{
+		{"done days count up", "DDD", []int{1, 2, 3}},
+		{"neutral days keep the streak", "DNND", []int{1, 1, 1, 2}},
+		{"missed day resets", "DDMD", []int{1, 2, 0, 1}},
+		{"pending today keeps the streak", "DDP", []int{1, 2, 2}},
+		{"missed after neutral resets", "DNMD", []int{1, 1, 0, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			days := testCalendar(testDay(1), tt.states)
+			got := dailyStreaks(days)
+			if len(got) != len(tt.want) {
+				t.Fatalf("dailyStreaks() returned %d days, want %d", len(got), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if got[days[i].Date] != want {
+					t.Errorf("streak on %s = %d, want %d", days[i].Date, got[days[i].Date], want)
+				}
+			}
+		})
+	}
+}
+
+func TestXPChanges(t *testing.T) {
+	tests := []struct {
+		name           string
+		worth          []loggedXP
+		earned         []loggedXP
+		exists         []uint
+		wantNew        []uint
+		wantCorrection int
+		wantDeleted    bool
+	}{
+		{
+			name:    "first log",
+			worth:   []loggedXP{{HabitLogID: 1, Amount: 10}},
+			exists:  []uint{1},
+			wantNew: []uint{1},
+		},
+		{
+			name:   "unchanged",
+			worth:  []loggedXP{{HabitLogID: 1, Amount: 10}, {HabitLogID: 2, Amount: 11}},
+			earned: []loggedXP{{HabitLogID: 1, Amount: 10}, {HabitLogID: 2, Amount: 11}},
+			exists: []uint{1, 2},
+		},
+		{
+			name:           "backfill raises the bonus of later logs in one correction",
+			worth:          []loggedXP{{HabitLogID: 1, Amount: 11}, {HabitLogID: 2, Amount: 11}, {HabitLogID: 3, Amount: 10}},
+			earned:         []loggedXP{{HabitLogID: 1, Amount: 10}, {HabitLogID: 2, Amount: 10}},
+			exists:         []uint{1, 2, 3},
+			wantNew:        []uint{3},
+			wantCorrection: 2,
+		},
+		{
+			name:           "earlier corrections count",
+			worth:          []loggedXP{{HabitLogID: 1, Amount: 11}, {HabitLogID: 2, Amount: 11}},
+			earned:         []loggedXP{{HabitLogID: 0, Amount: 2}, {HabitLogID: 1, Amount: 10}, {HabitLogID: 2, Amount: 10}},
+			exists:         []uint{1, 2},
+			wantCorrection: 0,
+		},
+		{
+			name:           "log no longer completed",
+			worth:          []loggedXP{{HabitLogID: 1, Amount: 10}},
+			earned:         []loggedXP{{HabitLogID: 1, Amount: 10}, {HabitLogID: 2, Amount: 11}},
+			exists:         []uint{1, 2},
+			wantCorrection: -11,
+		},
+		{
+			name:           "log deleted",
+			worth:          []loggedXP{{HabitLogID: 1, Amount: 10}},
+			earned:         []loggedXP{{HabitLogID: 1, Amount: 10}, {HabitLogID: 2, Amount: 11}},
+			exists:         []uint{1},
+			wantCorrection: -11,
+			wantDeleted:    true,
+		},
+		{
+			name:           "habit deleted",
+			earned:         []loggedXP{{HabitLogID: 0, Amount: 3}, {HabitLogID: 1, Amount: 10}, {HabitLogID: 2, Amount: 11}},
+			wantCorrection: -24,
+			wantDeleted:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exists := make(map[uint]bool, len(tt.exists))
+			for _, id := range tt.exists {
+				exists[id] = true
+			}
+			newLogs, correction, deleted := xpChanges(tt.worth, tt.earned, exists)
+
+			var newIDs []uint
+			for _, value := range newLogs {
+				newIDs = append(newIDs, value.HabitLogID)
+			}
+			if fmt.Sprint(newIDs) != fmt.Sprint(tt.wantNew) {
+				t.Errorf("new logs = %v, want %v", newIDs, tt.wantNew)
+			}
+			if correction != tt.wantCorrection {
+				t.Errorf("correction = %d, want %d", correction, tt.wantCorrection)
+			}
+			if deleted != tt.wantDeleted {
+				t.Errorf("deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
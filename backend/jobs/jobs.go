@@ -0,0 +1,26 @@
+package jobs
+
+import (
+	"log"
+	"time"
+)
+
+// Every runs fn once right away and then every interval in its own
+// goroutine until the process exits. Errors are logged and the job keeps
+// running.
+func Every(name string, interval time.Duration, fn func(now time.Time) error) {
+	go func() {
+		run := func(now time.Time) {
+			if err := fn(now); err != nil {
+				log.Printf("Job %s failed: %v", name, err)
+			}
+		}
+
+		run(time.Now())
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			run(now)
+		}
+	}()
+}
@@ -3,13 +3,14 @@ package main
 import (
 	"log"
 	"os"
+	"time"
 
-	"github.com/gin-contrib/cors"
-	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"habit-tracker/apperror"
 	"habit-tracker/database"
 	"habit-tracker/handlers"
-	"habit-tracker/middleware"
+	"habit-tracker/jobs"
+	"habit-tracker/storage"
 )
 
 func main() {
@@ -21,49 +22,20 @@ func main() {
 	// Initialize database
 	database.InitDB()
 
-	// Setup Gin router
-	r := gin.Default()
-
-	// CORS middleware
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		AllowCredentials: true,
-	}))
-
-	// Public routes
-	auth := r.Group("/api/auth")
-	{
-		auth.POST("/register", handlers.Register)
-		auth.POST("/login", handlers.Login)
-	}
+	// Initialize file storage for attachments
+	storage.Init()
 
-	// Protected routes
-	api := r.Group("/api")
-	api.Use(middleware.AuthMiddleware())
-	{
-		// User profile
-		api.GET("/auth/profile", handlers.GetProfile)
+	// Resume paused habits when their scheduled resume date arrives
+	jobs.Every("auto-resume", time.Minute, handlers.AutoResumeHabits)
 
-		// Categories
-		api.GET("/categories", handlers.GetCategories)
-		api.POST("/categories", handlers.CreateCategory)
-		api.PUT("/categories/:id", handlers.UpdateCategory)
-		api.DELETE("/categories/:id", handlers.DeleteCategory)
+	// Mark goals unreachable once their deadline can no longer be met
+	jobs.Every("goals", time.Hour, handlers.EvaluateGoals)
 
-		// Habits
-		api.GET("/habits", handlers.GetHabits)
-		api.POST("/habits", handlers.CreateHabit)
-		api.GET("/habits/:id", handlers.GetHabit)
-		api.PUT("/habits/:id", handlers.UpdateHabit)
-		api.DELETE("/habits/:id", handlers.DeleteHabit)
-		api.PATCH("/habits/:id/toggle", handlers.ToggleHabit)
+	// Report validation errors using JSON field names
+	apperror.UseJSONFieldNames()
 
-		// Habit logs
-		api.POST("/habits/:id/log", handlers.CreateHabitLog)
-		api.GET("/habits/:id/logs", handlers.GetHabitLogs)
-	}
+	// Setup Gin router
+	r := setupRouter()
 
 	port := os.Getenv("PORT")
 	if port == "" {
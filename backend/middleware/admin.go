@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"habit-tracker/apperror"
+	"habit-tracker/database"
+	"habit-tracker/models"
+)
+
+// AdminMiddleware must run after AuthMiddleware
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var user models.User
+		if err := database.DB.First(&user, userID).Error; err != nil || !user.IsAdmin {
+			apperror.Abort(c, apperror.Forbidden("Admin access required"))
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -1,34 +1,26 @@
 package middleware
 
 import (
-	"net/http"
 	"os"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"habit-tracker/apperror"
 )
 
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error":   true,
-				"message": "Authorization header required",
-			})
-			c.Abort()
+			apperror.Abort(c, apperror.Unauthorized(apperror.CodeUnauthorized, "Authorization header required"))
 			return
 		}
 
 		// Check if header starts with "Bearer "
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		if tokenString == authHeader {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error":   true,
-				"message": "Invalid authorization header format",
-			})
-			c.Abort()
+			apperror.Abort(c, apperror.Unauthorized(apperror.CodeUnauthorized, "Invalid authorization header format"))
 			return
 		}
 
@@ -41,34 +33,23 @@ func AuthMiddleware() gin.HandlerFunc {
 		})
 
 		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error":   true,
-				"message": "Invalid or expired token",
-			})
-			c.Abort()
+			apperror.Abort(c, apperror.Unauthorized(apperror.CodeInvalidToken, "Invalid or expired token"))
 			return
 		}
 
 		// Extract user ID from claims
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			if userID, exists := claims["user_id"]; exists {
-				c.Set("userID", uint(userID.(float64)))
-				c.Next()
-			} else {
-				c.JSON(http.StatusUnauthorized, gin.H{
-					"error":   true,
-					"message": "Invalid token claims",
-				})
-				c.Abort()
-				return
-			}
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error":   true,
-				"message": "Invalid token claims",
-			})
-			c.Abort()
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			apperror.Abort(c, apperror.Unauthorized(apperror.CodeInvalidToken, "Invalid token claims"))
+			return
+		}
+		userID, ok := claims["user_id"].(float64)
+		if !ok {
+			apperror.Abort(c, apperror.Unauthorized(apperror.CodeInvalidToken, "Invalid token claims"))
 			return
 		}
+
+		c.Set("userID", uint(userID))
+		c.Next()
 	}
 }
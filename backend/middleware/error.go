@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"habit-tracker/apperror"
+)
+
+// ErrorHandler renders the last error attached with c.Error as the standard
+// error envelope. Errors that are not *apperror.Error are treated as 500s so
+// internal details never reach the client.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		var appErr *apperror.Error
+		if !errors.As(err, &appErr) {
+			appErr = apperror.Internal("Internal server error", err)
+		}
+
+		if appErr.Err != nil {
+			log.Printf("request %s: %v", c.GetString("requestID"), appErr)
+		}
+
+		body := gin.H{
+			"error":   true,
+			"code":    appErr.Code,
+			"message": appErr.Message,
+		}
+		if len(appErr.Fields) > 0 {
+			body["fields"] = appErr.Fields
+		}
+		if requestID := c.GetString("requestID"); requestID != "" {
+			body["request_id"] = requestID
+		}
+
+		c.JSON(appErr.Status, body)
+	}
+}
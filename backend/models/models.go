@@ -1,14 +1,21 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 	"gorm.io/gorm"
+	"habit-tracker/stats"
 )
 
 type User struct {
 	ID           uint      `json:"id" gorm:"primaryKey"`
 	Email        string    `json:"email" gorm:"unique;not null"`
 	PasswordHash string    `json:"-" gorm:"not null"`
+	IsAdmin      bool      `json:"is_admin" gorm:"default:false"`
+	XP           int       `json:"xp" gorm:"not null;default:0"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 	
@@ -17,48 +24,497 @@ type User struct {
 	HabitLogs    []HabitLog    `json:"habit_logs,omitempty" gorm:"foreignKey:UserID"`
 }
 
+// HabitCategory groups habits. Categories form a tree through ParentID,
+// such as Health > Fitness > Running; root categories have no parent.
 type HabitCategory struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
+	ClientID  *string   `json:"client_id" gorm:"size:64;uniqueIndex"`
+	ParentID  *uint     `json:"parent_id" gorm:"index"`
 	Name      string    `json:"name" gorm:"not null"`
 	Color     string    `json:"color" gorm:"not null"`
+	Position  int       `json:"position" gorm:"not null;default:0"`
+	Version   uint      `json:"version" gorm:"not null;default:1"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	
 	// Relationships
-	Habits    []Habit   `json:"habits,omitempty" gorm:"foreignKey:CategoryID"`
+	Habits    []Habit         `json:"habits,omitempty" gorm:"foreignKey:CategoryID"`
+	Children  []HabitCategory `json:"children,omitempty" gorm:"foreignKey:ParentID"`
 }
 
 type Habit struct {
-	ID            uint      `json:"id" gorm:"primaryKey"`
-	UserID        uint      `json:"user_id" gorm:"not null"`
-	CategoryID    *uint     `json:"category_id"`
-	Name          string    `json:"name" gorm:"not null"`
-	Description   string    `json:"description"`
-	Color         string    `json:"color" gorm:"default:'#6366f1'"`
-	IsActive      bool      `json:"is_active" gorm:"default:true"`
-	TargetPerDay  int       `json:"target_per_day" gorm:"default:1"`
-	StartDate     time.Time `json:"start_date"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID                 uint           `json:"id" gorm:"primaryKey"`
+	UserID             uint           `json:"user_id" gorm:"not null;uniqueIndex:idx_habits_user_client"`
+	ClientID           *string        `json:"client_id" gorm:"size:64;uniqueIndex:idx_habits_user_client"`
+	CategoryID         *uint          `json:"category_id"`
+	Name               string         `json:"name" gorm:"not null"`
+	Description        string         `json:"description"`
+	Kind               string         `json:"kind" gorm:"size:16;not null;default:'build'"`
+	Unit               string         `json:"unit" gorm:"size:32"`
+	ChecklistThreshold int            `json:"checklist_threshold" gorm:"not null;default:100"`
+	Color              string         `json:"color" gorm:"default:'#6366f1'"`
+	TimeOfDay          string         `json:"time_of_day" gorm:"size:16;not null;default:'anytime'"`
+	Difficulty         string         `json:"difficulty" gorm:"size:16;not null;default:'medium'"`
+	Position           int            `json:"position" gorm:"not null;default:0;index"`
+	IsActive           bool           `json:"is_active" gorm:"default:true"`
+	TargetPerDay       int            `json:"target_per_day" gorm:"default:1"`
+	StartDate          time.Time      `json:"start_date"`
+	ArchivedAt         *time.Time     `json:"archived_at" gorm:"index"`
+	Outcome            string         `json:"outcome" gorm:"size:1000"`
+	ArchiveStats       *ArchivedStats `json:"archive_stats,omitempty" gorm:"type:json"`
+	Version            uint           `json:"version" gorm:"not null;default:1"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
 	
 	// Relationships
-	User          User          `json:"user,omitempty" gorm:"foreignKey:UserID"`
-	Category      *HabitCategory `json:"category,omitempty" gorm:"foreignKey:CategoryID"`
-	HabitLogs     []HabitLog    `json:"habit_logs,omitempty" gorm:"foreignKey:HabitID"`
+	User      User           `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Category  *HabitCategory `json:"category,omitempty" gorm:"foreignKey:CategoryID"`
+	HabitLogs []HabitLog     `json:"habit_logs,omitempty" gorm:"foreignKey:HabitID"`
+	Tags      []Tag          `json:"tags,omitempty" gorm:"many2many:habit_tags"`
 }
 
 type HabitLog struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	HabitID      uint       `json:"habit_id" gorm:"not null;uniqueIndex:idx_habit_logs_habit_day"`
+	UserID       uint       `json:"user_id" gorm:"not null;uniqueIndex:idx_habit_logs_user_client"`
+	ClientID     *string    `json:"client_id" gorm:"size:64;uniqueIndex:idx_habit_logs_user_client"`
+	Date         time.Time  `json:"date" gorm:"not null"`
+	// LogDate is the day of Date, kept by MySQL so a habit has at most one
+	// log per day
+	LogDate      time.Time  `json:"-" gorm:"->;type:date GENERATED ALWAYS AS (DATE(date)) STORED;uniqueIndex:idx_habit_logs_habit_day"`
+	Completed    bool       `json:"completed" gorm:"default:false"`
+	Status       string     `json:"status" gorm:"size:16;not null;default:'completed'"`
+	SkipReason   string     `json:"skip_reason" gorm:"size:255"`
+	Amount       int        `json:"amount" gorm:"not null;default:0"`
+	Note         string     `json:"note" gorm:"type:text;index:idx_habit_logs_note,class:FULLTEXT"`
+	Mood         *int       `json:"mood"`
+	Effort       *int       `json:"effort"`
+	Tags         StringList `json:"tags" gorm:"type:json"`
+	CheckedItems IDList     `json:"checked_items" gorm:"type:json"`
+	Version      uint       `json:"version" gorm:"not null;default:1"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	
+	// Relationships
+	Habit       Habit        `json:"habit,omitempty" gorm:"foreignKey:HabitID"`
+	User        User         `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Attachments []Attachment `json:"attachments,omitempty" gorm:"foreignKey:HabitLogID"`
+}
+
+// Attachment is a file, usually a photo, attached to a habit log. The file
+// itself lives in storage; URL and ThumbnailURL are signed on every read.
+type Attachment struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	HabitLogID   uint      `json:"habit_log_id" gorm:"not null;index"`
+	UserID       uint      `json:"user_id" gorm:"not null;index"`
+	FileName     string    `json:"file_name" gorm:"size:255;not null"`
+	ContentType  string    `json:"content_type" gorm:"size:64;not null"`
+	Size         int64     `json:"size" gorm:"not null"`
+	StorageKey   string    `json:"-" gorm:"size:255;not null"`
+	ThumbnailKey string    `json:"-" gorm:"size:255"`
+	URL          string    `json:"url" gorm:"-"`
+	ThumbnailURL string    `json:"thumbnail_url,omitempty" gorm:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	// Relationships
+	HabitLog     HabitLog  `json:"-" gorm:"foreignKey:HabitLogID"`
+}
+
+// Habit kinds. A build habit is something to do; a quit habit is something
+// to avoid, where every day without a relapse is a success and each log
+// records a relapse.
+const (
+	HabitKindBuild = "build"
+	HabitKindQuit  = "quit"
+)
+
+// Times of day a habit is done at. Today's habits are listed in sections
+// in this order.
+const (
+	TimeOfDayMorning   = "morning"
+	TimeOfDayAfternoon = "afternoon"
+	TimeOfDayEvening   = "evening"
+	TimeOfDayAnytime   = "anytime"
+)
+
+// TimesOfDay lists the times of day in section order
+var TimesOfDay = []string{TimeOfDayMorning, TimeOfDayAfternoon, TimeOfDayEvening, TimeOfDayAnytime}
+
+// Habit difficulties. Harder habits earn more XP per completed log.
+const (
+	DifficultyEasy   = "easy"
+	DifficultyMedium = "medium"
+	DifficultyHard   = "hard"
+)
+
+// UnitMinutes marks a habit whose target is a number of minutes. Only
+// such habits can be timed.
+const UnitMinutes = "minutes"
+
+// Habit log statuses. Completed mirrors Status == LogStatusCompleted.
+const (
+	LogStatusCompleted = "completed"
+	LogStatusSkipped   = "skipped"
+	LogStatusFailed    = "failed"
+)
+
+// Vacation is a period in which none of the user's habits are due. Both
+// dates are inclusive.
+type Vacation struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
-	HabitID   uint      `json:"habit_id" gorm:"not null"`
-	UserID    uint      `json:"user_id" gorm:"not null"`
-	Date      time.Time `json:"date" gorm:"not null"`
-	Completed bool      `json:"completed" gorm:"default:false"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	StartDate time.Time `json:"start_date" gorm:"not null"`
+	EndDate   time.Time `json:"end_date" gorm:"not null"`
+	Reason    string    `json:"reason" gorm:"size:255"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
-	
+}
+
+// StreakFreeze protects a habit's streak on a missed day. Users get a
+// limited number per month.
+type StreakFreeze struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	HabitID   uint      `json:"habit_id" gorm:"not null;uniqueIndex:idx_streak_freezes_habit_date"`
+	Date      time.Time `json:"date" gorm:"not null;uniqueIndex:idx_streak_freezes_habit_date"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HabitPause is an interval in which a habit was paused. PausedAt is when
+// the pause started, ResumedAt is nil while it lasts and ResumeAt is an
+// optional scheduled auto-resume.
+type HabitPause struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	HabitID   uint       `json:"habit_id" gorm:"not null;index"`
+	PausedAt  time.Time  `json:"paused_at" gorm:"not null"`
+	ResumeAt  *time.Time `json:"resume_at" gorm:"index"`
+	ResumedAt *time.Time `json:"resumed_at"`
+	Reason    string     `json:"reason" gorm:"size:255"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// HabitTemplate is a blueprint for a new habit. Templates without a UserID
+// form the built-in library; the others belong to a user, who can share one
+// through its ShareCode. Schedule is a suggestion shown to the user, such as
+// "daily" or "3x per week".
+type HabitTemplate struct {
+	ID                 uint       `json:"id" gorm:"primaryKey"`
+	UserID             *uint      `json:"user_id" gorm:"index"`
+	Name               string     `json:"name" gorm:"not null"`
+	Description        string     `json:"description"`
+	Kind               string     `json:"kind" gorm:"size:16;not null;default:'build'"`
+	Unit               string     `json:"unit" gorm:"size:32"`
+	TargetPerDay       int        `json:"target_per_day" gorm:"not null;default:1"`
+	Color              string     `json:"color" gorm:"default:'#6366f1'"`
+	CategoryID         *uint      `json:"category_id"`
+	Schedule           string     `json:"schedule" gorm:"size:64"`
+	ChecklistThreshold int        `json:"checklist_threshold" gorm:"not null;default:100"`
+	Items              StringList `json:"items" gorm:"type:json"`
+	ShareCode          *string    `json:"share_code" gorm:"size:16;uniqueIndex"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+
 	// Relationships
-	Habit     Habit     `json:"habit,omitempty" gorm:"foreignKey:HabitID"`
-	User      User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Category *HabitCategory `json:"category,omitempty" gorm:"foreignKey:CategoryID;constraint:OnDelete:SET NULL"`
+}
+
+// Tag is a user's label for habits, such as "morning" or "outdoors". Unlike
+// a category, a habit can have any number of tags.
+type Tag struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_tags_user_name"`
+	Name      string    `json:"name" gorm:"size:50;not null;uniqueIndex:idx_tags_user_name"`
+	Color     string    `json:"color" gorm:"default:'#6366f1'"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// HabitTag is the join table between habits and tags
+type HabitTag struct {
+	HabitID   uint      `json:"habit_id" gorm:"primaryKey"`
+	TagID     uint      `json:"tag_id" gorm:"primaryKey;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HabitItem is a step of a habit's checklist, such as one part of a morning
+// routine. Items are ordered by Position, starting at 0.
+type HabitItem struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	HabitID   uint      `json:"habit_id" gorm:"not null;index"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	Name      string    `json:"name" gorm:"size:100;not null"`
+	Position  int       `json:"position" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TimerSession is a timed session of a habit. A running session has no
+// StoppedAt; a stopped one is linked to the log of its day once the day's
+// sessions reach a minute, and a session that ran past midnight is split
+// into one session per day. Duration is in seconds.
+type TimerSession struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"user_id" gorm:"not null;index"`
+	HabitID    uint       `json:"habit_id" gorm:"not null;index"`
+	HabitLogID *uint      `json:"habit_log_id" gorm:"index"`
+	StartedAt  time.Time  `json:"started_at" gorm:"not null"`
+	StoppedAt  *time.Time `json:"stopped_at"`
+	Duration   int        `json:"duration"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+
+	// Relationships
+	Habit *Habit `json:"habit,omitempty" gorm:"foreignKey:HabitID"`
+}
+
+// Goal types. A count goal completes the habit Target times, a streak goal
+// keeps it up Target days in a row and an amount goal logs a total amount
+// of Target in the habit's unit, such as 500 km.
+const (
+	GoalTypeCount  = "count"
+	GoalTypeStreak = "streak"
+	GoalTypeAmount = "amount"
+)
+
+// Goal statuses. An unreachable goal becomes active again if backfilled
+// logs bring it back in reach; an achieved goal stays achieved.
+const (
+	GoalStatusActive      = "active"
+	GoalStatusAchieved    = "achieved"
+	GoalStatusUnreachable = "unreachable"
+)
+
+// GoalMilestones are the percentages of a goal's target at which a
+// milestone is recorded
+var GoalMilestones = []int{25, 50, 75, 100}
+
+// Goal is a target set on a habit. Only days from StartDate up to the
+// optional Deadline count toward it. Progress is kept up to date as the
+// habit's logs change: the days completed, the longest streak or the
+// amount logged.
+type Goal struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	UserID        uint       `json:"user_id" gorm:"not null;index"`
+	HabitID       uint       `json:"habit_id" gorm:"not null;index"`
+	Name          string     `json:"name" gorm:"size:255"`
+	Type          string     `json:"type" gorm:"size:16;not null"`
+	Target        int        `json:"target" gorm:"not null"`
+	StartDate     time.Time  `json:"start_date" gorm:"not null"`
+	Deadline      *time.Time `json:"deadline"`
+	Status        string     `json:"status" gorm:"size:16;not null;default:'active';index"`
+	Progress      int        `json:"progress" gorm:"not null;default:0"`
+	AchievedAt    *time.Time `json:"achieved_at"`
+	UnreachableAt *time.Time `json:"unreachable_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+
+	// Relationships
+	Milestones []GoalMilestone `json:"milestones,omitempty" gorm:"foreignKey:GoalID"`
+}
+
+// GoalMilestone records when a goal first reached a percentage of its
+// target
+type GoalMilestone struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	GoalID    uint      `json:"goal_id" gorm:"not null;uniqueIndex:idx_goal_milestones_goal_percent"`
+	Percent   int       `json:"percent" gorm:"not null;uniqueIndex:idx_goal_milestones_goal_percent"`
+	ReachedAt time.Time `json:"reached_at" gorm:"not null"`
+}
+
+// Event tells a user about something the server noticed on its own, such
+// as a goal being achieved. Clients poll for new events.
+type Event struct {
+	ID         uint            `json:"id" gorm:"primaryKey"`
+	UserID     uint            `json:"user_id" gorm:"not null;index"`
+	Type       string          `json:"type" gorm:"size:32;not null;index"`
+	EntityType string          `json:"entity_type" gorm:"size:32"`
+	EntityID   *uint           `json:"entity_id"`
+	Data       json.RawMessage `json:"data,omitempty" gorm:"type:json"`
+	CreatedAt  time.Time       `json:"created_at" gorm:"index"`
+}
+
+// Achievement rules. Each rule is a check with a threshold:
+//   - completed_logs: Threshold completed logs across all habits
+//   - streak: a habit reached a streak of Threshold days
+//   - perfect_week: a habit had Threshold Monday-to-Sunday weeks without a
+//     missed day
+//   - category_mastery: every current habit of a category was kept up for
+//     Threshold days in a row
+//   - comeback: a habit was completed again after at least Threshold
+//     missed days in a row
+const (
+	AchievementRuleCompletedLogs   = "completed_logs"
+	AchievementRuleStreak          = "streak"
+	AchievementRulePerfectWeek     = "perfect_week"
+	AchievementRuleCategoryMastery = "category_mastery"
+	AchievementRuleComeback        = "comeback"
+)
+
+// Achievement is a badge users earn by meeting its Rule with its
+// Threshold. New badges for an existing rule are just new rows.
+type Achievement struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Code        string    `json:"code" gorm:"size:64;not null;uniqueIndex"`
+	Name        string    `json:"name" gorm:"not null"`
+	Description string    `json:"description"`
+	Rule        string    `json:"rule" gorm:"size:32;not null"`
+	Threshold   int       `json:"threshold" gorm:"not null;default:1"`
+	Position    int       `json:"position" gorm:"not null;default:0"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// UserAchievement records when a user earned an achievement and, for
+// badges earned by one habit, which habit. Badges are never taken back.
+type UserAchievement struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	UserID        uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_user_achievements_user_achievement"`
+	AchievementID uint      `json:"achievement_id" gorm:"not null;uniqueIndex:idx_user_achievements_user_achievement"`
+	HabitID       *uint     `json:"habit_id"`
+	EarnedAt      time.Time `json:"earned_at" gorm:"not null"`
+}
+
+// XP event reasons
+const (
+	XPReasonLogCompleted = "log_completed"
+	XPReasonLogChanged   = "log_changed"
+	XPReasonLogDeleted   = "log_deleted"
+	XPReasonHabitChanged = "habit_changed"
+	XPReasonRecompute    = "recompute"
+)
+
+// XPEvent is a change to a user's XP earned by a habit. A completed log
+// earns an event of its own; edits and deletes add one correction for the
+// habit, with HabitLogID 0, rather than rewriting history. Streak is the
+// streak the log extended. Quit habits earn no XP.
+type XPEvent struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	UserID     uint      `json:"user_id" gorm:"not null;index"`
+	HabitID    uint      `json:"habit_id" gorm:"not null;index"`
+	HabitLogID uint      `json:"habit_log_id" gorm:"not null;index"`
+	Amount     int       `json:"amount" gorm:"not null"`
+	Reason     string    `json:"reason" gorm:"size:32;not null"`
+	Streak     int       `json:"streak" gorm:"not null;default:0"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
+
+// StringList is a list of strings stored as a JSON array
+type StringList []string
+
+// Value implements driver.Valuer
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	raw, err := json.Marshal([]string(l))
+	return string(raw), err
+}
+
+// Scan implements sql.Scanner
+func (l *StringList) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	default:
+		return fmt.Errorf("cannot scan %T into StringList", value)
+	}
+}
+
+// IDList is a list of IDs stored as a JSON array
+type IDList []uint
+
+// Value implements driver.Valuer
+func (l IDList) Value() (driver.Value, error) {
+	if l == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal([]uint(l))
+	return string(raw), err
+}
+
+// Scan implements sql.Scanner
+func (l *IDList) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	default:
+		return fmt.Errorf("cannot scan %T into IDList", value)
+	}
+}
+
+// ArchivedStats are a habit's lifetime stats, frozen when it is archived
+type ArchivedStats struct {
+	stats.Summary
+	TotalLogs   int64 `json:"total_logs"`
+	TotalAmount int64 `json:"total_amount"`
+}
+
+// Value implements driver.Valuer
+func (s ArchivedStats) Value() (driver.Value, error) {
+	raw, err := json.Marshal(s)
+	return string(raw), err
+}
+
+// Scan implements sql.Scanner
+func (s *ArchivedStats) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	default:
+		return fmt.Errorf("cannot scan %T into ArchivedStats", value)
+	}
+}
+
+// Tombstone records a deleted row so sync clients can remove their copy
+type Tombstone struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	UserID     *uint     `json:"user_id" gorm:"index"`
+	EntityType string    `json:"entity_type" gorm:"size:32;not null"`
+	EntityID   uint      `json:"entity_id" gorm:"not null"`
+	ClientID   *string   `json:"client_id" gorm:"size:64"`
+	DeletedAt  time.Time `json:"deleted_at" gorm:"not null;index"`
+}
+
+// AuditLog is an append-only record of a change made by a user
+type AuditLog struct {
+	ID         uint            `json:"id" gorm:"primaryKey"`
+	ActorID    *uint           `json:"actor_id" gorm:"index"`
+	Action     string          `json:"action" gorm:"size:32;not null;index"`
+	EntityType string          `json:"entity_type" gorm:"size:32;not null;index:idx_audit_entity"`
+	EntityID   *uint           `json:"entity_id" gorm:"index:idx_audit_entity"`
+	Before     json.RawMessage `json:"before,omitempty" gorm:"type:json"`
+	After      json.RawMessage `json:"after,omitempty" gorm:"type:json"`
+	Changes    json.RawMessage `json:"changes,omitempty" gorm:"type:json"`
+	IP         string          `json:"ip" gorm:"size:64"`
+	UserAgent  string          `json:"user_agent" gorm:"size:255"`
+	RequestID  string          `json:"request_id" gorm:"size:64;index"`
+	CreatedAt  time.Time       `json:"created_at" gorm:"index"`
+}
+
+var ErrAuditLogImmutable = errors.New("audit logs are append-only")
+
+// BeforeUpdate hook for AuditLog
+func (a *AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+// BeforeDelete hook for AuditLog
+func (a *AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
 }
 
 // BeforeCreate hook for Habit
@@ -66,5 +522,18 @@ func (h *Habit) BeforeCreate(tx *gorm.DB) error {
 	if h.StartDate.IsZero() {
 		h.StartDate = time.Now()
 	}
+	h.Version = 1
+	return nil
+}
+
+// BeforeCreate hook for HabitCategory
+func (c *HabitCategory) BeforeCreate(tx *gorm.DB) error {
+	c.Version = 1
+	return nil
+}
+
+// BeforeCreate hook for HabitLog
+func (l *HabitLog) BeforeCreate(tx *gorm.DB) error {
+	l.Version = 1
 	return nil
 }
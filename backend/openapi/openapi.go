@@ -0,0 +1,266 @@
+package openapi
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+	Tags       []Tag                `json:"tags,omitempty"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Tag struct {
+	Name string `json:"name"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// PathItem maps lower-case HTTP methods to operations
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Summary     string                `json:"summary"`
+	OperationID string                `json:"operationId"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Headers     map[string]*Header    `json:"headers,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+var (
+	buildOnce sync.Once
+	document  *Document
+)
+
+// Spec returns the OpenAPI document for every route in the API
+func Spec() *Document {
+	buildOnce.Do(func() {
+		document = build(operations)
+	})
+	return document
+}
+
+func Serve(c *gin.Context) {
+	c.JSON(http.StatusOK, Spec())
+}
+
+// pathParam matches gin's named (":id") and catch-all ("*key") parameters
+var pathParam = regexp.MustCompile(`([:*])(\w+)`)
+
+// ToOpenAPIPath converts a gin route ("/habits/:id") to OpenAPI form ("/habits/{id}")
+func ToOpenAPIPath(path string) string {
+	return pathParam.ReplaceAllString(path, "{$2}")
+}
+
+func build(ops []operation) *Document {
+	registry := newSchemaRegistry()
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info:    Info{Title: "Habit Tracker API", Version: "1.0.0"},
+		Paths:   make(map[string]*PathItem),
+		Components: Components{
+			SecuritySchemes: map[string]*SecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+
+	errorSchema := registry.schemaOf(ErrorResponse{})
+	tags := make(map[string]bool)
+
+	for _, op := range ops {
+		path := ToOpenAPIPath(op.Path)
+		item, exists := doc.Paths[path]
+		if !exists {
+			item = &PathItem{}
+			doc.Paths[path] = item
+		}
+
+		operation := &Operation{
+			Summary:     op.Summary,
+			OperationID: op.ID,
+			Tags:        []string{op.Tag},
+			Responses:   make(map[string]*Response),
+			Security:    []map[string][]string{},
+		}
+		tags[op.Tag] = true
+		if !op.Public {
+			operation.Security = []map[string][]string{{"bearerAuth": {}}}
+		}
+
+		for _, match := range pathParam.FindAllStringSubmatch(op.Path, -1) {
+			schema := idSchema()
+			if match[1] == "*" {
+				// Catch-all parameters hold a path
+				schema = stringSchema()
+			}
+			operation.Parameters = append(operation.Parameters, Parameter{
+				Name:     match[2],
+				In:       "path",
+				Required: true,
+				Schema:   schema,
+			})
+		}
+		if op.List {
+			operation.Parameters = append(operation.Parameters, paginationParams...)
+		}
+		operation.Parameters = append(operation.Parameters, op.Query...)
+		operation.Parameters = append(operation.Parameters, op.Headers...)
+		if op.Conditional {
+			operation.Parameters = append(operation.Parameters, conditionalHeader(op.Method))
+		}
+
+		if op.Request != nil {
+			operation.RequestBody = &RequestBody{
+				Required: true,
+				Content: map[string]*MediaType{
+					op.requestContentType(): {Schema: registry.schemaOf(op.Request)},
+				},
+			}
+		}
+
+		success := &Response{
+			Description: op.Summary,
+			Content: map[string]*MediaType{
+				"application/json": {Schema: envelope(registry, op)},
+			},
+		}
+		operation.Responses[strconv.Itoa(op.status())] = success
+
+		errorStatuses := op.errorStatuses()
+		if op.Conditional {
+			success.Headers = map[string]*Header{"ETag": etagHeader}
+			if op.Method == http.MethodGet {
+				operation.Responses[strconv.Itoa(http.StatusNotModified)] = &Response{
+					Description: "The representation matches If-None-Match",
+					Headers:     map[string]*Header{"ETag": etagHeader},
+				}
+			} else {
+				errorStatuses = append(errorStatuses, http.StatusPreconditionFailed)
+			}
+		}
+		for _, status := range errorStatuses {
+			operation.Responses[strconv.Itoa(status)] = &Response{
+				Description: http.StatusText(status),
+				Content: map[string]*MediaType{
+					"application/json": {Schema: errorSchema},
+				},
+			}
+		}
+
+		(*item)[strings.ToLower(op.Method)] = operation
+	}
+
+	doc.Components.Schemas = registry.components
+	for name := range tags {
+		doc.Tags = append(doc.Tags, Tag{Name: name})
+	}
+	sort.Slice(doc.Tags, func(i, j int) bool { return doc.Tags[i].Name < doc.Tags[j].Name })
+
+	return doc
+}
+
+var etagHeader = &Header{
+	Description: "Version of the returned representation",
+	Schema:      &Schema{Type: "string"},
+}
+
+// conditionalHeader documents If-None-Match for reads and If-Match for writes
+func conditionalHeader(method string) Parameter {
+	if method == http.MethodGet {
+		return Parameter{
+			Name:        "If-None-Match",
+			In:          "header",
+			Description: "Reply 304 Not Modified when the ETag still matches",
+			Schema:      &Schema{Type: "string"},
+		}
+	}
+	return Parameter{
+		Name:        "If-Match",
+		In:          "header",
+		Description: "Only apply the change if the resource still has this ETag, otherwise reply 412",
+		Schema:      &Schema{Type: "string"},
+	}
+}
+
+// envelope wraps the response data in the standard success envelope
+func envelope(registry *schemaRegistry, op operation) *Schema {
+	if op.Raw {
+		return &Schema{Type: "object"}
+	}
+
+	schema := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"error":   {Type: "boolean"},
+			"message": {Type: "string"},
+		},
+		Required: []string{"error", "message"},
+	}
+
+	if op.Response != nil {
+		data := registry.schemaOf(op.Response)
+		if op.List {
+			data = &Schema{Type: "array", Items: data}
+			schema.Properties["pagination"] = registry.schemaOf(Pagination{})
+			schema.Required = append(schema.Required, "pagination")
+		}
+		schema.Properties["data"] = data
+		schema.Required = append(schema.Required, "data")
+	}
+
+	return schema
+}
@@ -0,0 +1,175 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is the subset of the OpenAPI 3 schema object used by this API
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaRegistry turns Go types into schemas, collecting named structs into
+// components so recursive relationships (Habit -> User -> Habits) terminate.
+type schemaRegistry struct {
+	components map[string]*Schema
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{components: make(map[string]*Schema)}
+}
+
+func (r *schemaRegistry) schemaOf(v interface{}) *Schema {
+	return r.schemaFor(reflect.TypeOf(v))
+}
+
+func (r *schemaRegistry) schemaFor(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawType:
+		return &Schema{Description: "Arbitrary JSON value"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := r.schemaFor(t.Elem())
+		if schema.Ref != "" {
+			return schema
+		}
+		clone := *schema
+		clone.Nullable = true
+		return &clone
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64", Minimum: float(0)}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: r.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.structSchema(t)
+		}
+		name := t.Name()
+		if _, exists := r.components[name]; !exists {
+			// Reserve the name before recursing so cycles resolve to a $ref
+			r.components[name] = &Schema{}
+			*r.components[name] = *r.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default:
+		return &Schema{}
+	}
+}
+
+func (r *schemaRegistry) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		// encoding/json promotes the fields of embedded structs
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			embedded := r.structSchema(field.Type)
+			for key, property := range embedded.Properties {
+				schema.Properties[key] = property
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := r.schemaFor(field.Type)
+		required := applyBindingRules(property, field.Tag.Get("binding"))
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	}
+
+	return schema
+}
+
+// applyBindingRules mirrors gin's binding tags onto the schema and reports
+// whether the field is required
+func applyBindingRules(schema *Schema, binding string) bool {
+	if binding == "" || schema.Ref != "" {
+		return strings.Contains(binding, "required")
+	}
+
+	required := false
+	for _, rule := range strings.Split(binding, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+		case "email":
+			schema.Format = "email"
+		case "oneof":
+			schema.Enum = strings.Fields(param)
+		case "min", "max", "gte", "lte":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			isMin := name == "min" || name == "gte"
+			if schema.Type == "string" {
+				if isMin {
+					schema.MinLength = &n
+				} else {
+					schema.MaxLength = &n
+				}
+			} else if isMin {
+				schema.Minimum = float(float64(n))
+			} else {
+				schema.Maximum = float(float64(n))
+			}
+		}
+	}
+	return required
+}
+
+func float(v float64) *float64 {
+	return &v
+}
@@ -0,0 +1,598 @@
+package openapi
+
+import (
+	"net/http"
+
+	"habit-tracker/handlers"
+	"habit-tracker/models"
+)
+
+// operation describes one registered route. Every route added in routes.go
+// needs an entry here; TestOpenAPICoversAllRoutes enforces it.
+type operation struct {
+	Method      string
+	Path        string
+	ID          string
+	Summary     string
+	Tag         string
+	Public      bool
+	Request     interface{}
+	RequestType string
+	Response    interface{}
+	List        bool
+	Raw         bool
+	Conditional bool
+	Status      int
+	Query       []Parameter
+	Headers     []Parameter
+	Errors      []int
+}
+
+func (op operation) status() int {
+	if op.Status != 0 {
+		return op.Status
+	}
+	return http.StatusOK
+}
+
+func (op operation) requestContentType() string {
+	if op.RequestType != "" {
+		return op.RequestType
+	}
+	return "application/json"
+}
+
+func (op operation) errorStatuses() []int {
+	statuses := []int{http.StatusBadRequest, http.StatusInternalServerError}
+	if !op.Public {
+		statuses = append(statuses, http.StatusUnauthorized)
+	}
+	return append(statuses, op.Errors...)
+}
+
+// ErrorResponse is the envelope rendered by middleware.ErrorHandler
+type ErrorResponse struct {
+	Error     bool              `json:"error"`
+	Code      string            `json:"code"`
+	Message   string            `json:"message"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+}
+
+// FileUpload is the multipart form of an attachment upload
+type FileUpload struct {
+	File string `json:"file" binding:"required"`
+}
+
+// Pagination is an alias so the schema is named after the response field
+type Pagination = handlers.Pagination
+
+var paginationParams = []Parameter{
+	query("limit", "Maximum number of items to return (1-500, default 100)", &Schema{Type: "integer", Minimum: float(1), Maximum: float(500)}),
+	query("cursor", "Opaque cursor taken from pagination.next_cursor", stringSchema()),
+	query("sort", "Comma separated sort fields, prefix with - for descending", stringSchema()),
+}
+
+func query(name, description string, schema *Schema) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+func stringSchema() *Schema {
+	return &Schema{Type: "string"}
+}
+
+func boolSchema() *Schema {
+	return &Schema{Type: "boolean"}
+}
+
+func dateSchema() *Schema {
+	return &Schema{Type: "string", Format: "date"}
+}
+
+func ratingSchema() *Schema {
+	return &Schema{Type: "integer", Minimum: float(1), Maximum: float(5)}
+}
+
+func idSchema() *Schema {
+	return &Schema{Type: "integer", Format: "int64", Minimum: float(1)}
+}
+
+var operations = []operation{
+	// Auth
+	{
+		Method: http.MethodPost, Path: "/api/auth/register", ID: "register", Tag: "auth",
+		Summary: "Register a new user", Public: true,
+		Request: handlers.RegisterRequest{}, Response: handlers.AuthResponse{},
+		Status: http.StatusCreated, Errors: []int{http.StatusConflict},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/auth/login", ID: "login", Tag: "auth",
+		Summary: "Log in with email and password", Public: true,
+		Request: handlers.LoginRequest{}, Response: handlers.AuthResponse{},
+		Errors: []int{http.StatusUnauthorized},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/auth/profile", ID: "getProfile", Tag: "auth",
+		Summary:  "Get the current user's profile with their XP level (only completed logs of build habits earn XP)",
+		Response: handlers.Profile{}, Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodPut, Path: "/api/auth/password", ID: "changePassword", Tag: "auth",
+		Summary: "Change the current user's password",
+		Request: handlers.ChangePasswordRequest{},
+	},
+
+	// Categories
+	{
+		Method: http.MethodGet, Path: "/api/categories", ID: "listCategories", Conditional: true, Tag: "categories",
+		Summary: "List categories", Response: models.HabitCategory{}, List: true,
+		Query: []Parameter{
+			query("parent_id", "Only the direct children of this category", idSchema()),
+			query("q", "Search by name", stringSchema()),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/categories/tree", ID: "getCategoryTree", Tag: "categories",
+		Summary: "All categories nested under their parents", Response: []models.HabitCategory{},
+	},
+	{
+		Method: http.MethodPut, Path: "/api/categories/order", ID: "reorderCategories", Tag: "categories",
+		Summary: "Reorder categories; the listed ones swap the positions they held", Request: handlers.CategoryOrderRequest{},
+		Response: []models.HabitCategory{},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/categories", ID: "createCategory", Tag: "categories",
+		Summary: "Create a category", Request: models.HabitCategory{}, Response: models.HabitCategory{},
+		Status: http.StatusCreated, Errors: []int{http.StatusConflict},
+	},
+	{
+		Method: http.MethodPut, Path: "/api/categories/:id", ID: "updateCategory", Conditional: true, Tag: "categories",
+		Summary: "Update a category", Request: models.HabitCategory{}, Response: models.HabitCategory{},
+		Errors: []int{http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		Method: http.MethodPatch, Path: "/api/categories/:id", ID: "patchCategory", Conditional: true, Tag: "categories",
+		Summary: "Partially update a category (JSON Merge Patch)", Request: handlers.CategoryPatch{},
+		RequestType: handlers.MergePatchContentType, Response: models.HabitCategory{},
+		Errors: []int{http.StatusNotFound, http.StatusConflict, http.StatusUnsupportedMediaType},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/categories/:id", ID: "deleteCategory", Conditional: true, Tag: "categories",
+		Summary: "Delete a category, moving its children and habits to its parent",
+		Errors:  []int{http.StatusNotFound, http.StatusConflict},
+	},
+
+	// Habits
+	{
+		Method: http.MethodGet, Path: "/api/habits", ID: "listHabits", Conditional: true, Tag: "habits",
+		Summary: "List the current user's habits", Response: models.Habit{}, List: true,
+		Query: append([]Parameter{
+			query("is_active", "Filter by active state", boolSchema()),
+			query("archived", "List archived instead of current habits", boolSchema()),
+			query("category_id", "Filter by category", idSchema()),
+			query("q", "Search by name", stringSchema()),
+		}, tagQuery...),
+	},
+	{
+		Method: http.MethodPost, Path: "/api/habits", ID: "createHabit", Tag: "habits",
+		Summary: "Create a habit", Request: models.Habit{}, Response: models.Habit{},
+		Status: http.StatusCreated, Errors: []int{http.StatusUnprocessableEntity},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/habits/archived", ID: "listArchivedHabits", Tag: "habits",
+		Summary:  "List archived habits with their lifetime stats frozen at archive time",
+		Response: models.Habit{}, List: true,
+	},
+	{
+		Method: http.MethodGet, Path: "/api/habits/today", ID: "listTodayHabits", Tag: "habits",
+		Summary: "Habits due today with today's log, in time-of-day sections", Response: []handlers.TodaySection{},
+	},
+	{
+		Method: http.MethodPut, Path: "/api/habits/order", ID: "reorderHabits", Tag: "habits",
+		Summary: "Reorder habits; the listed ones swap the positions they held", Request: handlers.HabitOrderRequest{},
+		Response: []models.Habit{},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/habits/:id", ID: "getHabit", Conditional: true, Tag: "habits",
+		Summary: "Get a habit", Response: models.Habit{}, Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodPut, Path: "/api/habits/:id", ID: "updateHabit", Conditional: true, Tag: "habits",
+		Summary: "Update a habit", Request: models.Habit{}, Response: models.Habit{},
+		Errors: []int{http.StatusNotFound, http.StatusUnprocessableEntity},
+	},
+	{
+		Method: http.MethodPatch, Path: "/api/habits/:id", ID: "patchHabit", Conditional: true, Tag: "habits",
+		Summary: "Partially update a habit (JSON Merge Patch)", Request: handlers.HabitPatch{},
+		RequestType: handlers.MergePatchContentType, Response: models.Habit{},
+		Errors: []int{http.StatusNotFound, http.StatusUnprocessableEntity, http.StatusUnsupportedMediaType},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/habits/:id", ID: "deleteHabit", Conditional: true, Tag: "habits",
+		Summary: "Delete a habit and its logs", Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodPatch, Path: "/api/habits/:id/toggle", ID: "toggleHabit", Conditional: true, Tag: "habits",
+		Summary: "Toggle a habit between active and inactive", Response: models.Habit{},
+		Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/habits/:id/pause", ID: "pauseHabit", Conditional: true, Tag: "habits",
+		Summary: "Pause a habit, optionally until a resume date", Request: handlers.PauseRequest{},
+		Response: models.Habit{}, Errors: []int{http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/habits/:id/resume", ID: "resumeHabit", Conditional: true, Tag: "habits",
+		Summary: "Resume a paused habit", Response: models.Habit{},
+		Errors: []int{http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/habits/:id/pauses", ID: "listHabitPauses", Tag: "habits",
+		Summary: "List the pause intervals of a habit", Response: models.HabitPause{}, List: true,
+		Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/habits/:id/archive", ID: "archiveHabit", Conditional: true, Tag: "habits",
+		Summary: "Archive a habit and freeze its lifetime stats", Request: handlers.ArchiveRequest{},
+		Response: models.Habit{}, Errors: []int{http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/habits/:id/unarchive", ID: "unarchiveHabit", Conditional: true, Tag: "habits",
+		Summary: "Bring an archived habit back", Response: models.Habit{},
+		Errors: []int{http.StatusNotFound, http.StatusConflict},
+	},
+
+	// Templates
+	{
+		Method: http.MethodGet, Path: "/api/templates", ID: "listTemplates", Tag: "templates",
+		Summary: "List the built-in template library and the user's own templates", Response: models.HabitTemplate{}, List: true,
+		Query: []Parameter{
+			query("source", "Only built-in (builtin) or own (mine) templates", &Schema{Type: "string", Enum: []string{"builtin", "mine"}}),
+			query("q", "Search by name", stringSchema()),
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/templates", ID: "createTemplate", Tag: "templates",
+		Summary: "Create a personal template", Request: handlers.TemplateRequest{}, Response: models.HabitTemplate{},
+		Status: http.StatusCreated,
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/templates/:id", ID: "deleteTemplate", Tag: "templates",
+		Summary: "Delete a personal template", Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/templates/:id/export", ID: "exportTemplate", Tag: "templates",
+		Summary: "Get the code others can import a personal template with", Response: handlers.TemplateExport{},
+		Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/templates/import", ID: "importTemplate", Tag: "templates",
+		Summary: "Copy a shared template into the user's templates", Request: handlers.ImportTemplateRequest{},
+		Response: models.HabitTemplate{}, Status: http.StatusCreated, Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/habits/:id/template", ID: "saveHabitAsTemplate", Tag: "templates",
+		Summary: "Save a habit and its checklist as a personal template", Request: handlers.SaveTemplateRequest{},
+		Response: models.HabitTemplate{}, Status: http.StatusCreated, Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/habits/from-template/:id", ID: "createHabitFromTemplate", Tag: "templates",
+		Summary: "Create a habit and its checklist from a template", Request: handlers.FromTemplateRequest{},
+		Response: models.Habit{}, Status: http.StatusCreated,
+		Errors: []int{http.StatusNotFound, http.StatusUnprocessableEntity},
+	},
+
+	// Tags
+	{
+		Method: http.MethodGet, Path: "/api/tags", ID: "listTags", Tag: "tags",
+		Summary: "List the user's tags", Response: models.Tag{}, List: true,
+		Query: []Parameter{query("q", "Search by name", stringSchema())},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/tags", ID: "createTag", Tag: "tags",
+		Summary: "Create a tag", Request: handlers.TagRequest{}, Response: models.Tag{},
+		Status: http.StatusCreated, Errors: []int{http.StatusConflict},
+	},
+	{
+		Method: http.MethodPut, Path: "/api/tags/:id", ID: "updateTag", Tag: "tags",
+		Summary: "Rename or recolor a tag", Request: handlers.TagRequest{}, Response: models.Tag{},
+		Errors: []int{http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/tags/:id", ID: "deleteTag", Tag: "tags",
+		Summary: "Delete a tag and remove it from its habits", Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/tags/:id/merge", ID: "mergeTag", Tag: "tags",
+		Summary: "Move a tag's habits to another tag and delete it", Request: handlers.TagMergeRequest{},
+		Response: models.Tag{}, Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/habits/:id/tags", ID: "attachHabitTags", Conditional: true, Tag: "tags",
+		Summary: "Add tags to a habit", Request: handlers.HabitTagsRequest{}, Response: models.Habit{},
+		Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/habits/:id/tags/:tagId", ID: "detachHabitTag", Conditional: true, Tag: "tags",
+		Summary: "Remove a tag from a habit", Response: models.Habit{}, Errors: []int{http.StatusNotFound},
+	},
+
+	// Checklists
+	{
+		Method: http.MethodGet, Path: "/api/habits/:id/items", ID: "listHabitItems", Tag: "checklists",
+		Summary: "List a habit's checklist items in order", Response: []models.HabitItem{},
+		Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/habits/:id/items", ID: "createHabitItem", Tag: "checklists",
+		Summary: "Add a checklist item, appended unless a position is given", Request: handlers.HabitItemRequest{},
+		Response: models.HabitItem{}, Status: http.StatusCreated, Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodPut, Path: "/api/habits/:id/items/order", ID: "reorderHabitItems", Tag: "checklists",
+		Summary: "Reorder a habit's checklist", Request: handlers.HabitItemOrderRequest{},
+		Response: []models.HabitItem{}, Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodPut, Path: "/api/habits/:id/items/:itemId", ID: "updateHabitItem", Tag: "checklists",
+		Summary: "Rename a checklist item", Request: handlers.HabitItemRequest{}, Response: models.HabitItem{},
+		Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/habits/:id/items/:itemId", ID: "deleteHabitItem", Tag: "checklists",
+		Summary: "Delete a checklist item", Errors: []int{http.StatusNotFound},
+	},
+
+	// Habit logs
+	{
+		Method: http.MethodPost, Path: "/api/habits/:id/log", ID: "createHabitLog", Tag: "logs",
+		Summary: "Log a habit for a day", Request: models.HabitLog{}, Response: models.HabitLog{},
+		Status: http.StatusCreated, Errors: []int{http.StatusNotFound, http.StatusUnprocessableEntity},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/habits/:id/logs", ID: "listHabitLogs", Conditional: true, Tag: "logs",
+		Summary: "List logs of a habit", Response: models.HabitLog{}, List: true,
+		Errors: []int{http.StatusNotFound},
+		Query: append([]Parameter{
+			query("from", "First day to include (YYYY-MM-DD)", dateSchema()),
+			query("to", "Last day to include (YYYY-MM-DD)", dateSchema()),
+			query("completed", "Filter by completion", boolSchema()),
+		}, journalQuery...),
+	},
+	{
+		Method: http.MethodPost, Path: "/api/habits/:id/logs/range", ID: "createHabitLogRange", Tag: "logs",
+		Summary: "Mark every day of a date range as completed, skipped or failed",
+		Request: handlers.LogRangeRequest{}, Response: handlers.LogRangeResponse{},
+		Errors: []int{http.StatusNotFound, http.StatusUnprocessableEntity},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/habits/:id/logs/:logId", ID: "getHabitLog", Conditional: true, Tag: "logs",
+		Summary: "Get a habit log", Response: models.HabitLog{}, Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodPut, Path: "/api/habits/:id/logs/:logId", ID: "updateHabitLog", Conditional: true, Tag: "logs",
+		Summary: "Update a habit log", Request: models.HabitLog{}, Response: models.HabitLog{},
+		Errors: []int{http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+	{
+		Method: http.MethodPatch, Path: "/api/habits/:id/logs/:logId", ID: "patchHabitLog", Conditional: true, Tag: "logs",
+		Summary: "Partially update a habit log (JSON Merge Patch)", Request: handlers.HabitLogPatch{},
+		RequestType: handlers.MergePatchContentType, Response: models.HabitLog{},
+		Errors: []int{http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity, http.StatusUnsupportedMediaType},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/habits/:id/logs/:logId", ID: "deleteHabitLog", Conditional: true, Tag: "logs",
+		Summary: "Delete a habit log", Errors: []int{http.StatusNotFound, http.StatusUnprocessableEntity},
+	},
+
+	// Attachments
+	{
+		Method: http.MethodPost, Path: "/api/habits/:id/logs/:logId/attachments", ID: "uploadAttachment", Tag: "attachments",
+		Summary: "Attach a photo or PDF to a habit log", Request: FileUpload{}, RequestType: "multipart/form-data",
+		Response: models.Attachment{}, Status: http.StatusCreated,
+		Errors: []int{http.StatusNotFound, http.StatusConflict, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/habits/:id/logs/:logId/attachments", ID: "listAttachments", Tag: "attachments",
+		Summary: "List the attachments of a habit log with signed download URLs", Response: []models.Attachment{},
+		Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/habits/:id/logs/:logId/attachments/:attachmentId", ID: "getAttachment", Tag: "attachments",
+		Summary: "Get an attachment with signed download URLs", Response: models.Attachment{},
+		Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/habits/:id/logs/:logId/attachments/:attachmentId", ID: "deleteAttachment", Tag: "attachments",
+		Summary: "Delete an attachment and its files", Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/files/*key", ID: "downloadFile", Tag: "attachments",
+		Summary: "Download a file through a signed URL (local storage only)", Public: true, Raw: true,
+		Query: []Parameter{
+			query("expires", "Expiry of the URL as a Unix timestamp", stringSchema()),
+			query("signature", "Signature of the URL", stringSchema()),
+		},
+		Errors: []int{http.StatusForbidden, http.StatusNotFound},
+	},
+
+	{
+		Method: http.MethodPost, Path: "/api/logs/batch", ID: "createHabitLogsBatch", Tag: "logs",
+		Summary: "Log several habits at once, reporting the result of each item",
+		Request: handlers.BatchLogRequest{}, Response: handlers.BatchLogResponse{},
+		Status: http.StatusCreated,
+	},
+
+	{
+		Method: http.MethodGet, Path: "/api/journal", ID: "listJournal", Conditional: true, Tag: "logs",
+		Summary: "List log notes across all habits in chronological order", Response: models.HabitLog{}, List: true,
+		Query: append([]Parameter{
+			query("from", "First day to include (YYYY-MM-DD)", dateSchema()),
+			query("to", "Last day to include (YYYY-MM-DD)", dateSchema()),
+			query("habit_id", "Filter by habit", idSchema()),
+		}, journalQuery...),
+	},
+
+	// Stats and streak protection
+	{
+		Method: http.MethodGet, Path: "/api/habits/:id/stats", ID: "getHabitStats", Tag: "stats",
+		Summary: "Streaks, completion rate and per-day states of a habit", Response: handlers.HabitStats{},
+		Errors: []int{http.StatusNotFound},
+		Query: []Parameter{
+			query("from", "First day of the totals (YYYY-MM-DD), default the habit's start date", dateSchema()),
+			query("to", "Last day of the totals (YYYY-MM-DD), default today", dateSchema()),
+		},
+	},
+
+	{
+		Method: http.MethodGet, Path: "/api/stats", ID: "getStatsOverview", Tag: "stats",
+		Summary: "Completion of the user's current habits, optionally by category or tags", Response: handlers.OverviewStats{},
+		Query: append([]Parameter{
+			query("from", "First day of the totals (YYYY-MM-DD), default 30 days ago", dateSchema()),
+			query("to", "Last day of the totals (YYYY-MM-DD), default today", dateSchema()),
+			query("category_id", "Filter by category, including its subcategories", idSchema()),
+		}, tagQuery...),
+	},
+	{
+		Method: http.MethodGet, Path: "/api/stats/categories", ID: "getCategoryStats", Tag: "stats",
+		Summary: "Completion per category, rolled up from subcategories", Response: []handlers.CategoryStats{},
+		Query: []Parameter{
+			query("from", "First day of the totals (YYYY-MM-DD), default 30 days ago", dateSchema()),
+			query("to", "Last day of the totals (YYYY-MM-DD), default today", dateSchema()),
+		},
+	},
+
+	// Goals
+	{
+		Method: http.MethodGet, Path: "/api/goals", ID: "listGoals", Tag: "goals",
+		Summary: "List goals with their percentage complete and projected completion date", Response: handlers.GoalProgress{}, List: true,
+		Query: []Parameter{
+			query("habit_id", "Only goals of this habit", idSchema()),
+			query("status", "Filter by status", &Schema{Type: "string", Enum: []string{models.GoalStatusActive, models.GoalStatusAchieved, models.GoalStatusUnreachable}}),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/goals/:id", ID: "getGoal", Tag: "goals",
+		Summary: "Get a goal with its progress and milestones", Response: handlers.GoalProgress{},
+		Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/goals/:id", ID: "deleteGoal", Tag: "goals",
+		Summary: "Delete a goal", Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/habits/:id/goals", ID: "createGoal", Tag: "goals",
+		Summary: "Set a goal on a habit", Request: handlers.GoalRequest{}, Response: handlers.GoalProgress{},
+		Status: http.StatusCreated, Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/events", ID: "listEvents", Tag: "goals",
+		Summary: "List events such as goals achieved or becoming unreachable, newest first", Response: models.Event{}, List: true,
+		Query: []Parameter{
+			query("type", "Filter by event type", stringSchema()),
+			query("after_id", "Only events after this one, for polling", idSchema()),
+		},
+	},
+
+	{
+		Method: http.MethodGet, Path: "/api/achievements", ID: "listAchievements", Tag: "achievements",
+		Summary: "List every achievement with when the user earned it", Response: []handlers.AchievementStatus{},
+		Query: []Parameter{query("earned", "Only earned (true) or locked (false) achievements", boolSchema())},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/xp/events", ID: "listXPEvents", Tag: "achievements",
+		Summary: "List the XP the user earned per completed log or lost or regained in corrections, newest first (quit habits earn no XP)", Response: models.XPEvent{}, List: true,
+		Query: []Parameter{query("habit_id", "Only XP of this habit", idSchema())},
+	},
+
+	// Timers
+	{
+		Method: http.MethodPost, Path: "/api/habits/:id/timer/start", ID: "startTimer", Tag: "timers",
+		Summary: "Start timing a habit measured in minutes", Response: models.TimerSession{},
+		Status: http.StatusCreated, Errors: []int{http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/habits/:id/timer/stop", ID: "stopTimer", Tag: "timers",
+		Summary: "Stop a habit's timer and add the time to the day's log", Response: handlers.TimerStopResponse{},
+		Errors: []int{http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/timers/active", ID: "getActiveTimers", Tag: "timers",
+		Summary: "List the user's running timers", Response: []models.TimerSession{},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/habits/:id/freezes", ID: "createStreakFreeze", Tag: "stats",
+		Summary: "Use a streak freeze on a missed day", Request: handlers.StreakFreezeRequest{},
+		Response: models.StreakFreeze{}, Status: http.StatusCreated,
+		Errors: []int{http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/habits/:id/freezes/:freezeId", ID: "deleteStreakFreeze", Tag: "stats",
+		Summary: "Remove a streak freeze and return it to the allowance", Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/freezes", ID: "getStreakFreezes", Tag: "stats",
+		Summary: "Streak freezes used in a month and the remaining allowance", Response: handlers.StreakFreezeAllowance{},
+		Query: []Parameter{query("month", "Month in YYYY-MM format, default the current month", stringSchema())},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/vacations", ID: "listVacations", Conditional: true, Tag: "stats",
+		Summary: "List the user's vacations", Response: models.Vacation{}, List: true,
+	},
+	{
+		Method: http.MethodPost, Path: "/api/vacations", ID: "createVacation", Tag: "stats",
+		Summary: "Add a vacation, during which no habits are due", Request: handlers.VacationRequest{},
+		Response: models.Vacation{}, Status: http.StatusCreated, Errors: []int{http.StatusConflict},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/vacations/:id", ID: "deleteVacation", Tag: "stats",
+		Summary: "Delete a vacation", Errors: []int{http.StatusNotFound},
+	},
+
+	// Sync
+	{
+		Method: http.MethodPost, Path: "/api/sync", ID: "sync", Tag: "sync",
+		Summary: "Apply offline changes and fetch server changes since the last sync",
+		Request: handlers.SyncRequest{}, Response: handlers.SyncResponse{},
+	},
+
+	// Audit
+	{
+		Method: http.MethodGet, Path: "/api/audit", ID: "listAuditLogs", Conditional: true, Tag: "audit",
+		Summary: "List the current user's audit events", Response: models.AuditLog{}, List: true,
+		Query: auditQuery,
+	},
+	{
+		Method: http.MethodGet, Path: "/api/admin/audit", ID: "listAllAuditLogs", Conditional: true, Tag: "admin",
+		Summary: "List audit events of all users (admin only)", Response: models.AuditLog{}, List: true,
+		Errors: []int{http.StatusForbidden},
+		Query:  append([]Parameter{query("actor_id", "Filter by acting user", idSchema())}, auditQuery...),
+	},
+
+	// Meta
+	{
+		Method: http.MethodGet, Path: "/api/openapi.json", ID: "getOpenAPI", Tag: "meta",
+		Summary: "This OpenAPI document", Public: true, Raw: true,
+	},
+}
+
+// journalQuery are the journaling filters of the log list and the journal
+var tagQuery = []Parameter{
+	query("tags", "Comma separated tag IDs", stringSchema()),
+	query("tag_match", "Match habits with any (default) or all of the tags", &Schema{Type: "string", Enum: []string{"any", "all"}}),
+}
+
+var journalQuery = []Parameter{
+	query("mood_min", "Minimum mood (1-5)", ratingSchema()),
+	query("mood_max", "Maximum mood (1-5)", ratingSchema()),
+	query("effort_min", "Minimum effort (1-5)", ratingSchema()),
+	query("effort_max", "Maximum effort (1-5)", ratingSchema()),
+	query("tag", "Only logs with this tag", stringSchema()),
+	query("q", "Full-text search over notes", stringSchema()),
+}
+
+var auditQuery = []Parameter{
+	query("action", "Filter by action (create, update, delete, login, ...)", stringSchema()),
+	query("entity_type", "Filter by entity type (habit, category, habit_log, user)", stringSchema()),
+	query("entity_id", "Filter by entity ID", idSchema()),
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"habit-tracker/apperror"
+	"habit-tracker/handlers"
+	"habit-tracker/middleware"
+	"habit-tracker/openapi"
+)
+
+func setupRouter() *gin.Engine {
+	r := gin.Default()
+	r.Use(middleware.RequestIDMiddleware())
+	r.Use(middleware.ErrorHandler())
+	r.NoRoute(func(c *gin.Context) {
+		apperror.Abort(c, apperror.NotFound(apperror.CodeNotFound, "Route not found"))
+	})
+
+	// CORS middleware
+	r.Use(cors.New(cors.Config{
+		AllowOrigins:     []string{"http://localhost:3000"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "If-Match", "If-None-Match", middleware.RequestIDHeader},
+		ExposeHeaders:    []string{"ETag", middleware.RequestIDHeader},
+		AllowCredentials: true,
+	}))
+
+	// API description
+	r.GET("/api/openapi.json", openapi.Serve)
+
+	// Signed attachment downloads of the local storage driver
+	r.GET("/api/files/*key", handlers.ServeFile)
+
+	// Public routes
+	auth := r.Group("/api/auth")
+	{
+		auth.POST("/register", handlers.Register)
+		auth.POST("/login", handlers.Login)
+	}
+
+	// Protected routes
+	api := r.Group("/api")
+	api.Use(middleware.AuthMiddleware())
+	{
+		// User profile
+		api.GET("/auth/profile", handlers.GetProfile)
+		api.PUT("/auth/password", handlers.ChangePassword)
+
+		// Categories
+		api.GET("/categories", handlers.GetCategories)
+		api.GET("/categories/tree", handlers.GetCategoryTree)
+		api.PUT("/categories/order", handlers.ReorderCategories)
+		api.POST("/categories", handlers.CreateCategory)
+		api.PUT("/categories/:id", handlers.UpdateCategory)
+		api.PATCH("/categories/:id", handlers.PatchCategory)
+		api.DELETE("/categories/:id", handlers.DeleteCategory)
+
+		// Habits
+		api.GET("/habits", handlers.GetHabits)
+		api.POST("/habits", handlers.CreateHabit)
+		api.GET("/habits/archived", handlers.GetArchivedHabits)
+		api.GET("/habits/today", handlers.GetTodayHabits)
+		api.PUT("/habits/order", handlers.ReorderHabits)
+		api.GET("/habits/:id", handlers.GetHabit)
+		api.PUT("/habits/:id", handlers.UpdateHabit)
+		api.PATCH("/habits/:id", handlers.PatchHabit)
+		api.DELETE("/habits/:id", handlers.DeleteHabit)
+		api.PATCH("/habits/:id/toggle", handlers.ToggleHabit)
+		api.POST("/habits/:id/pause", handlers.PauseHabit)
+		api.POST("/habits/:id/resume", handlers.ResumeHabit)
+		api.GET("/habits/:id/pauses", handlers.GetHabitPauses)
+		api.POST("/habits/:id/archive", handlers.ArchiveHabit)
+		api.POST("/habits/:id/unarchive", handlers.UnarchiveHabit)
+
+		// Templates
+		api.GET("/templates", handlers.GetTemplates)
+		api.POST("/templates", handlers.CreateTemplate)
+		api.DELETE("/templates/:id", handlers.DeleteTemplate)
+		api.POST("/templates/:id/export", handlers.ExportTemplate)
+		api.POST("/templates/import", handlers.ImportTemplate)
+		api.POST("/habits/:id/template", handlers.SaveHabitAsTemplate)
+		api.POST("/habits/from-template/:id", handlers.CreateHabitFromTemplate)
+
+		// Tags
+		api.GET("/tags", handlers.GetTags)
+		api.POST("/tags", handlers.CreateTag)
+		api.PUT("/tags/:id", handlers.UpdateTag)
+		api.DELETE("/tags/:id", handlers.DeleteTag)
+		api.POST("/tags/:id/merge", handlers.MergeTag)
+		api.POST("/habits/:id/tags", handlers.AttachHabitTags)
+		api.DELETE("/habits/:id/tags/:tagId", handlers.DetachHabitTag)
+
+		// Checklists
+		api.GET("/habits/:id/items", handlers.GetHabitItems)
+		api.POST("/habits/:id/items", handlers.CreateHabitItem)
+		api.PUT("/habits/:id/items/order", handlers.ReorderHabitItems)
+		api.PUT("/habits/:id/items/:itemId", handlers.UpdateHabitItem)
+		api.DELETE("/habits/:id/items/:itemId", handlers.DeleteHabitItem)
+
+		// Habit logs
+		api.POST("/habits/:id/log", handlers.CreateHabitLog)
+		api.GET("/habits/:id/logs", handlers.GetHabitLogs)
+		api.POST("/habits/:id/logs/range", handlers.CreateHabitLogRange)
+		api.GET("/habits/:id/logs/:logId", handlers.GetHabitLog)
+		api.PUT("/habits/:id/logs/:logId", handlers.UpdateHabitLog)
+		api.PATCH("/habits/:id/logs/:logId", handlers.PatchHabitLog)
+		api.DELETE("/habits/:id/logs/:logId", handlers.DeleteHabitLog)
+		api.GET("/habits/:id/stats", handlers.GetHabitStats)
+		api.GET("/stats", handlers.GetStatsOverview)
+		api.GET("/stats/categories", handlers.GetCategoryStats)
+
+		// Goals
+		api.GET("/goals", handlers.GetGoals)
+		api.GET("/goals/:id", handlers.GetGoal)
+		api.DELETE("/goals/:id", handlers.DeleteGoal)
+		api.POST("/habits/:id/goals", handlers.CreateGoal)
+		api.GET("/events", handlers.GetEvents)
+		api.GET("/achievements", handlers.GetAchievements)
+		api.GET("/xp/events", handlers.GetXPEvents)
+
+		// Timers
+		api.POST("/habits/:id/timer/start", handlers.StartTimer)
+		api.POST("/habits/:id/timer/stop", handlers.StopTimer)
+		api.GET("/timers/active", handlers.GetActiveTimers)
+
+		// Streak protection
+		api.POST("/habits/:id/freezes", handlers.CreateStreakFreeze)
+		api.DELETE("/habits/:id/freezes/:freezeId", handlers.DeleteStreakFreeze)
+		api.GET("/freezes", handlers.GetStreakFreezes)
+		api.GET("/vacations", handlers.GetVacations)
+		api.POST("/vacations", handlers.CreateVacation)
+		api.DELETE("/vacations/:id", handlers.DeleteVacation)
+
+		// Attachments
+		api.POST("/habits/:id/logs/:logId/attachments", handlers.UploadAttachment)
+		api.GET("/habits/:id/logs/:logId/attachments", handlers.GetAttachments)
+		api.GET("/habits/:id/logs/:logId/attachments/:attachmentId", handlers.GetAttachment)
+		api.DELETE("/habits/:id/logs/:logId/attachments/:attachmentId", handlers.DeleteAttachment)
+		api.POST("/logs/batch", handlers.CreateHabitLogsBatch)
+		api.GET("/journal", handlers.GetJournal)
+
+		// Offline sync
+		api.POST("/sync", handlers.Sync)
+
+		// Audit log
+		api.GET("/audit", handlers.GetAuditLogs)
+	}
+
+	// Admin routes
+	admin := r.Group("/api/admin")
+	admin.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	{
+		admin.GET("/audit", handlers.GetAllAuditLogs)
+	}
+
+	return r
+}
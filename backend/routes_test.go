@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"habit-tracker/openapi"
+)
+
+func TestOpenAPICoversAllRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	spec := openapi.Spec()
+
+	registered := make(map[string]bool)
+	for _, route := range setupRouter().Routes() {
+		path := openapi.ToOpenAPIPath(route.Path)
+		method := strings.ToLower(route.Method)
+		registered[method+" "+path] = true
+
+		item, exists := spec.Paths[path]
+		if !exists {
+			t.Errorf("route %s %s is missing from the OpenAPI spec", route.Method, route.Path)
+			continue
+		}
+		if _, exists := (*item)[method]; !exists {
+			t.Errorf("route %s %s is missing from the OpenAPI spec", route.Method, route.Path)
+		}
+	}
+
+	for path, item := range spec.Paths {
+		for method := range *item {
+			if !registered[method+" "+path] {
+				t.Errorf("OpenAPI spec documents %s %s but no such route is registered", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
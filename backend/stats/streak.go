@@ -0,0 +1,59 @@
+// Package stats computes streaks and completion rates from a habit's days.
+package stats
+
+// State is the outcome of a habit on one day
+type State int
+
+const (
+	// Missed days are due but were not completed; they break a streak
+	Missed State = iota
+	// Done days were completed
+	Done
+	// Neutral days (skipped, vacation, frozen, paused) are not due: they
+	// neither extend nor break a streak
+	Neutral
+	// Pending is today before it has been completed. It does not break the
+	// streak yet.
+	Pending
+)
+
+// Summary of a run of days
+type Summary struct {
+	CurrentStreak  int     `json:"current_streak"`
+	LongestStreak  int     `json:"longest_streak"`
+	CompletedDays  int     `json:"completed_days"`
+	MissedDays     int     `json:"missed_days"`
+	NeutralDays    int     `json:"neutral_days"`
+	DueDays        int     `json:"due_days"`
+	CompletionRate float64 `json:"completion_rate"`
+}
+
+// Summarize computes streaks and totals over days in chronological order.
+// The completion rate is completed days over due days, so neutral days do
+// not lower it.
+func Summarize(days []State) Summary {
+	var summary Summary
+	streak := 0
+	for _, state := range days {
+		switch state {
+		case Done:
+			summary.CompletedDays++
+			streak++
+			if streak > summary.LongestStreak {
+				summary.LongestStreak = streak
+			}
+		case Missed:
+			summary.MissedDays++
+			streak = 0
+		case Neutral:
+			summary.NeutralDays++
+		}
+	}
+
+	summary.CurrentStreak = streak
+	summary.DueDays = summary.CompletedDays + summary.MissedDays
+	if summary.DueDays > 0 {
+		summary.CompletionRate = float64(summary.CompletedDays) / float64(summary.DueDays)
+	}
+	return summary
+}
@@ -0,0 +1,65 @@
+package stats
+
+import "testing"
+
+func TestSummarize(t *testing.T) {
+	const (
+		D = Done
+		M = Missed
+		N = Neutral
+		P = Pending
+	)
+
+	tests := []struct {
+		name string
+		days []State
+		want Summary
+	}{
+		{
+			name: "empty",
+			want: Summary{},
+		},
+		{
+			name: "all done",
+			days: []State{D, D, D},
+			want: Summary{CurrentStreak: 3, LongestStreak: 3, CompletedDays: 3, DueDays: 3, CompletionRate: 1},
+		},
+		{
+			name: "neutral days between done days keep the streak",
+			days: []State{D, N, N, D, N, D},
+			want: Summary{CurrentStreak: 3, LongestStreak: 3, CompletedDays: 3, NeutralDays: 3, DueDays: 3, CompletionRate: 1},
+		},
+		{
+			name: "trailing neutral days keep the current streak",
+			days: []State{D, D, N, N},
+			want: Summary{CurrentStreak: 2, LongestStreak: 2, CompletedDays: 2, NeutralDays: 2, DueDays: 2, CompletionRate: 1},
+		},
+		{
+			name: "pending today does not break the streak",
+			days: []State{D, D, P},
+			want: Summary{CurrentStreak: 2, LongestStreak: 2, CompletedDays: 2, DueDays: 2, CompletionRate: 1},
+		},
+		{
+			name: "missed day resets the current streak",
+			days: []State{D, D, D, M, D},
+			want: Summary{CurrentStreak: 1, LongestStreak: 3, CompletedDays: 4, MissedDays: 1, DueDays: 5, CompletionRate: 0.8},
+		},
+		{
+			name: "missed day after neutral days still resets",
+			days: []State{D, N, M, N, P},
+			want: Summary{CurrentStreak: 0, LongestStreak: 1, CompletedDays: 1, MissedDays: 1, NeutralDays: 2, DueDays: 2, CompletionRate: 0.5},
+		},
+		{
+			name: "only neutral days",
+			days: []State{N, N},
+			want: Summary{NeutralDays: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Summarize(tt.days); got != tt.want {
+				t.Errorf("Summarize() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
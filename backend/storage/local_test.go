@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLocal(t *testing.T) *Local {
+	t.Helper()
+	local, err := NewLocal(t.TempDir(), "/api/files/", []byte("secret"))
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+	return local
+}
+
+// signedQuery signs key and returns the expires and signature of the URL
+func signedQuery(t *testing.T, local *Local, key string, expires time.Duration) (string, string) {
+	t.Helper()
+	signed, err := local.SignedURL(context.Background(), key, expires)
+	if err != nil {
+		t.Fatalf("SignedURL(%q): %v", key, err)
+	}
+	parsed, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("SignedURL(%q) returned an invalid URL %q: %v", key, signed, err)
+	}
+	if want := "/api/files/" + key; parsed.Path != want {
+		t.Errorf("SignedURL(%q) path = %q, want %q", key, parsed.Path, want)
+	}
+	return parsed.Query().Get("expires"), parsed.Query().Get("signature")
+}
+
+func TestNewLocalRequiresKey(t *testing.T) {
+	if _, err := NewLocal(t.TempDir(), "/api/files", nil); err == nil {
+		t.Error("NewLocal without a signing key succeeded")
+	}
+}
+
+func TestLocalVerify(t *testing.T) {
+	local := newTestLocal(t)
+	key := "users/1/logs/2/abc.jpg"
+	expires, signature := signedQuery(t, local, key, time.Minute)
+	expired, expiredSignature := signedQuery(t, local, key, -time.Minute)
+
+	other, err := NewLocal(t.TempDir(), "/api/files", []byte("other secret"))
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		local     *Local
+		key       string
+		expires   string
+		signature string
+		want      bool
+	}{
+		{"valid", local, key, expires, signature, true},
+		{"expired", local, key, expired, expiredSignature, false},
+		{"other key", local, "users/1/logs/2/other.jpg", expires, signature, false},
+		{"extended expiry", local, key, expires + "0", signature, false},
+		{"tampered signature", local, key, expires, strings.Repeat("0", len(signature)), false},
+		{"missing signature", local, key, expires, "", false},
+		{"invalid expiry", local, key, "soon", signature, false},
+		{"other signing key", other, key, expires, signature, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.local.Verify(tt.key, tt.expires, tt.signature); got != tt.want {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalPathRejectsTraversal(t *testing.T) {
+	local := newTestLocal(t)
+
+	for _, key := range []string{"", "/", "..", "../secret", "users/../../secret", "users/1/..", `users\..\..\secret`} {
+		if path, err := local.Path(key); err == nil {
+			t.Errorf("Path(%q) = %q, want an error", key, path)
+		}
+		if _, err := local.SignedURL(context.Background(), key, time.Minute); err == nil {
+			t.Errorf("SignedURL(%q) succeeded, want an error", key)
+		}
+		if err := local.Put(context.Background(), key, strings.NewReader("data"), 4, "text/plain"); err == nil {
+			t.Errorf("Put(%q) succeeded, want an error", key)
+		}
+	}
+
+	path, err := local.Path("/users/1/a.jpg")
+	if err != nil {
+		t.Fatalf("Path: %v", err)
+	}
+	if want := filepath.Join(local.root, "users", "1", "a.jpg"); path != want {
+		t.Errorf("Path() = %q, want %q", path, want)
+	}
+}
+
+func TestLocalPutAndDelete(t *testing.T) {
+	local := newTestLocal(t)
+	ctx := context.Background()
+	key := "users/1/logs/2/abc.txt"
+
+	if err := local.Put(ctx, key, strings.NewReader("hello"), 5, "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	path, _ := local.Path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading stored file: %v", err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("stored %q, want %q", data, "hello")
+	}
+
+	// No temporary upload files are left next to the stored one
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory has %d entries, want 1", len(entries))
+	}
+
+	if err := local.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("file still exists after Delete: %v", err)
+	}
+	if err := local.Delete(ctx, key); err != nil {
+		t.Errorf("Delete of a missing file: %v", err)
+	}
+}
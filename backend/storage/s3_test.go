@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is a minimal stand-in for an S3-compatible service that records
+// the requests it receives
+type fakeS3 struct {
+	mu           sync.Mutex
+	bucketExists bool
+	requests     []fakeS3Request
+}
+
+type fakeS3Request struct {
+	method      string
+	path        string
+	contentType string
+	body        string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, fakeS3Request{
+		method:      r.Method,
+		path:        r.URL.Path,
+		contentType: r.Header.Get("Content-Type"),
+		body:        string(body),
+	})
+
+	isBucket := strings.Count(strings.Trim(r.URL.Path, "/"), "/") == 0
+	switch {
+	case isBucket && r.Method == http.MethodHead:
+		if !f.bucketExists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	case isBucket && r.Method == http.MethodPut:
+		f.bucketExists = true
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPut:
+		w.Header().Set("ETag", `"d41d8cd98f00b204e9800998ecf8427e"`)
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodDelete:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+// find returns the first recorded request with the given method and path
+func (f *fakeS3) find(method, path string) (fakeS3Request, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, request := range f.requests {
+		if request.method == method && request.path == path {
+			return request, true
+		}
+	}
+	return fakeS3Request{}, false
+}
+
+func newTestS3(t *testing.T, fake *fakeS3) (*S3, *httptest.Server) {
+	t.Helper()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	s3, err := NewS3(S3Config{
+		Endpoint:  strings.TrimPrefix(server.URL, "http://"),
+		AccessKey: "access",
+		SecretKey: "secret",
+		Bucket:    "habits",
+		Region:    "us-east-1",
+	})
+	if err != nil {
+		t.Fatalf("NewS3: %v", err)
+	}
+	return s3, server
+}
+
+func TestNewS3RequiresEndpointAndBucket(t *testing.T) {
+	if _, err := NewS3(S3Config{Bucket: "habits"}); err == nil {
+		t.Error("NewS3 without an endpoint succeeded")
+	}
+	if _, err := NewS3(S3Config{Endpoint: "localhost:9000"}); err == nil {
+		t.Error("NewS3 without a bucket succeeded")
+	}
+}
+
+func TestNewS3CreatesMissingBucket(t *testing.T) {
+	fake := &fakeS3{}
+	newTestS3(t, fake)
+
+	if _, ok := fake.find(http.MethodPut, "/habits/"); !ok {
+		t.Errorf("bucket was not created; requests: %+v", fake.requests)
+	}
+}
+
+func TestS3PutAndDelete(t *testing.T) {
+	fake := &fakeS3{bucketExists: true}
+	s3, _ := newTestS3(t, fake)
+	ctx := context.Background()
+	key := "users/1/logs/2/abc.jpg"
+
+	if err := s3.Put(ctx, key, strings.NewReader("image data"), int64(len("image data")), "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	put, ok := fake.find(http.MethodPut, "/habits/"+key)
+	if !ok {
+		t.Fatalf("no PUT for the object; requests: %+v", fake.requests)
+	}
+	if put.contentType != "image/jpeg" {
+		t.Errorf("Content-Type = %q, want %q", put.contentType, "image/jpeg")
+	}
+	// Plain HTTP uploads may be sent with chunked signatures around the data
+	if !strings.Contains(put.body, "image data") {
+		t.Errorf("body %q does not contain the uploaded data", put.body)
+	}
+
+	if err := s3.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok := fake.find(http.MethodDelete, "/habits/"+key); !ok {
+		t.Errorf("no DELETE for the object; requests: %+v", fake.requests)
+	}
+}
+
+func TestS3SignedURL(t *testing.T) {
+	fake := &fakeS3{bucketExists: true}
+	s3, server := newTestS3(t, fake)
+	key := "users/1/logs/2/abc.jpg"
+
+	signed, err := s3.SignedURL(context.Background(), key, 15*time.Minute)
+	if err != nil {
+		t.Fatalf("SignedURL: %v", err)
+	}
+	parsed, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("SignedURL returned an invalid URL %q: %v", signed, err)
+	}
+	if want := strings.TrimPrefix(server.URL, "http://"); parsed.Host != want {
+		t.Errorf("host = %q, want %q", parsed.Host, want)
+	}
+	if want := "/habits/" + key; parsed.Path != want {
+		t.Errorf("path = %q, want %q", parsed.Path, want)
+	}
+	query := parsed.Query()
+	if got := query.Get("X-Amz-Expires"); got != "900" {
+		t.Errorf("X-Amz-Expires = %q, want %q", got, "900")
+	}
+	if query.Get("X-Amz-Signature") == "" {
+		t.Error("URL has no X-Amz-Signature")
+	}
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Storage keeps uploaded files. Keys are slash separated paths such as
+// "users/1/logs/2/abc.jpg".
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a URL that downloads the object until expires has passed
+	SignedURL(ctx context.Context, key string, expires time.Duration) (string, error)
+}
+
+// Files is the storage used by the handlers, set by Init
+var Files Storage
+
+// Init configures Files from the environment. STORAGE_DRIVER selects "local"
+// (default) or "s3".
+func Init() {
+	var err error
+
+	switch driver := os.Getenv("STORAGE_DRIVER"); driver {
+	case "", "local":
+		dir := os.Getenv("STORAGE_LOCAL_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+		key := []byte(os.Getenv("STORAGE_SIGNING_KEY"))
+		if len(key) == 0 {
+			key = subkey(os.Getenv("JWT_SECRET"), "storage-url")
+		}
+		Files, err = NewLocal(dir, "/api/files", key)
+	case "s3":
+		useSSL, _ := strconv.ParseBool(os.Getenv("S3_USE_SSL"))
+		Files, err = NewS3(S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			Region:    os.Getenv("S3_REGION"),
+			UseSSL:    useSSL,
+		})
+	default:
+		log.Fatal("Unknown STORAGE_DRIVER: ", driver)
+	}
+	if err != nil {
+		log.Fatal("Failed to initialize storage:", err)
+	}
+}
+
+// subkey derives a key for one purpose from secret, so a signature made with
+// it is never valid as a signature of secret itself
+func subkey(secret, purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}